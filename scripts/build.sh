@@ -9,3 +9,4 @@ export GOOS=linux
 
 go build -o $BINDIR/supply logstash/supply/cli
 go build -o $BINDIR/finalize logstash/finalize/cli
+go build -o $BINDIR/launcher logstash/launcher/cli
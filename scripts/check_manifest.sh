@@ -0,0 +1,11 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+ROOTDIR="$( dirname "$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )" )"
+output_dir=$(mktemp -d -t manifestcheckXXX)
+
+export GOPATH=$ROOTDIR
+export GO111MODULE=off
+
+go build -o $output_dir/manifestcheck logstash/manifestcheck/cli
+$output_dir/manifestcheck $ROOTDIR
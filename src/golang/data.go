@@ -2,6 +2,7 @@ package golang
 
 import (
 	"fmt"
+	"sort"
 )
 
 func ReleaseYAML(startCmd string) string {
@@ -12,6 +13,23 @@ default_process_types:
 	return fmt.Sprintf(release, startCmd)
 }
 
+// ReleaseYAMLWithProcesses returns the release YAML with additional process types (sorted by name) next to web
+func ReleaseYAMLWithProcesses(startCmd string, processes map[string]string) string {
+	release := ReleaseYAML(startCmd)
+
+	names := make([]string, 0, len(processes))
+	for name := range processes {
+		if name != "web" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		release += fmt.Sprintf("    %s: %s\n", name, processes[name])
+	}
+	return release
+}
+
 func GoScript() string {
 	return "PATH=$PATH:$HOME/bin\n"
 }
@@ -0,0 +1,125 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// file extensions accepted in the application's "certificates" directory
+var Extensions = []string{".crt", ".pem", ".cer", ".der"}
+
+// HasExtension reports if the file name has one of the accepted certificate extensions
+func HasExtension(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the file name without the certificate extension
+func Name(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
+}
+
+// ParseFile reads all certificates of a PEM bundle or a DER encoded file
+func ParseFile(file string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse returns all certificates of PEM (one or more blocks) or DER (one or more concatenated certificates) data
+func Parse(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		rest := data
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" && block.Type != "TRUSTED CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate #%d in PEM data: %s", len(certs)+1, err.Error())
+			}
+			certs = append(certs, cert)
+		}
+	} else {
+		var err error
+		certs, err = x509.ParseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DER data: %s", err.Error())
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+// Aliases derives one alias per certificate of a bundle: "name", "name-1", "name-2", ...
+func Aliases(name string, count int) []string {
+	aliases := make([]string, count)
+	for i := 0; i < count; i++ {
+		if i == 0 {
+			aliases[i] = name
+		} else {
+			aliases[i] = fmt.Sprintf("%s-%d", name, i)
+		}
+	}
+	return aliases
+}
+
+// EncodePEM returns the certificate PEM encoded
+func EncodePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// Describe returns subject, issuer and expiry of a certificate in one line
+func Describe(cert *x509.Certificate) string {
+	return fmt.Sprintf("subject='%s' issuer='%s' expires=%s", cert.Subject.CommonName, cert.Issuer.CommonName, cert.NotAfter.UTC().Format("2006-01-02"))
+}
+
+// Expired reports if the certificate is not valid anymore at the given time
+func Expired(cert *x509.Certificate, now time.Time) bool {
+	return now.After(cert.NotAfter)
+}
+
+// ExpiresWithin reports if the certificate expires within the given number of days
+func ExpiresWithin(cert *x509.Certificate, now time.Time, days int) bool {
+	return now.AddDate(0, 0, days).After(cert.NotAfter)
+}
+
+// DaysLeft returns the number of full days until the certificate expires (negative if expired)
+func DaysLeft(cert *x509.Certificate, now time.Time) int {
+	return int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24))
+}
+
+// CheckChain verifies that every certificate of a bundle is signed by the following one
+func CheckChain(certs []*x509.Certificate) error {
+	for i := 0; i+1 < len(certs); i++ {
+		if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+			return fmt.Errorf("certificate '%s' is not signed by the following certificate '%s': %s", certs[i].Subject.CommonName, certs[i+1].Subject.CommonName, err.Error())
+		}
+	}
+	return nil
+}
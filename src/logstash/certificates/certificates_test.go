@@ -0,0 +1,119 @@
+package certificates_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+
+	"logstash/certificates"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func newCertificate(commonName string) *x509.Certificate {
+	cert, _ := newSignedCertificate(commonName, time.Now().Add(time.Hour), nil, nil)
+	return cert
+}
+
+func newSignedCertificate(commonName string, notAfter time.Time, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	Expect(err).To(BeNil())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).To(BeNil())
+	return cert, key
+}
+
+var _ = Describe("Certificates", func() {
+	var root, intermediate *x509.Certificate
+
+	BeforeEach(func() {
+		root = newCertificate("root")
+		intermediate = newCertificate("intermediate")
+	})
+
+	Describe("Parse", func() {
+		It("splits PEM bundles", func() {
+			data := append(certificates.EncodePEM(intermediate), certificates.EncodePEM(root)...)
+
+			certs, err := certificates.Parse(data)
+			Expect(err).To(BeNil())
+			Expect(certs).To(HaveLen(2))
+			Expect(certs[0].Subject.CommonName).To(Equal("intermediate"))
+			Expect(certs[1].Subject.CommonName).To(Equal("root"))
+		})
+
+		It("parses DER data", func() {
+			certs, err := certificates.Parse(root.Raw)
+			Expect(err).To(BeNil())
+			Expect(certs).To(HaveLen(1))
+			Expect(certs[0].Subject.CommonName).To(Equal("root"))
+		})
+
+		It("fails on invalid data", func() {
+			_, err := certificates.Parse([]byte("-----BEGIN CERTIFICATE-----\nbm8gY2VydA==\n-----END CERTIFICATE-----\n"))
+			Expect(err).NotTo(BeNil())
+
+			_, err = certificates.Parse([]byte("no certificate"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	It("derives an alias per certificate", func() {
+		Expect(certificates.Aliases("elasticsearch", 3)).To(Equal([]string{"elasticsearch", "elasticsearch-1", "elasticsearch-2"}))
+	})
+
+	It("accepts several extensions", func() {
+		Expect(certificates.HasExtension("ca.PEM")).To(BeTrue())
+		Expect(certificates.HasExtension("ca.der")).To(BeTrue())
+		Expect(certificates.HasExtension("ca.key")).To(BeFalse())
+		Expect(certificates.Name("ca.cer")).To(Equal("ca"))
+	})
+
+	Describe("expiry", func() {
+		It("detects expired and expiring certificates", func() {
+			now := time.Now()
+			cert, _ := newSignedCertificate("soon", now.AddDate(0, 0, 10), nil, nil)
+
+			Expect(certificates.Expired(cert, now)).To(BeFalse())
+			Expect(certificates.ExpiresWithin(cert, now, 30)).To(BeTrue())
+			Expect(certificates.ExpiresWithin(cert, now, 5)).To(BeFalse())
+			Expect(certificates.DaysLeft(cert, now)).To(Equal(9))
+			Expect(certificates.Expired(cert, now.AddDate(0, 0, 11))).To(BeTrue())
+			Expect(certificates.Describe(cert)).To(ContainSubstring("subject='soon' issuer='soon' expires="))
+		})
+	})
+
+	Describe("CheckChain", func() {
+		It("accepts a bundle ordered from leaf to root", func() {
+			rootCert, rootKey := newSignedCertificate("root", time.Now().Add(time.Hour), nil, nil)
+			leafCert, _ := newSignedCertificate("leaf", time.Now().Add(time.Hour), rootCert, rootKey)
+
+			Expect(certificates.CheckChain([]*x509.Certificate{leafCert, rootCert})).To(Succeed())
+		})
+
+		It("reports certificates not signed by the following one", func() {
+			Expect(certificates.CheckChain([]*x509.Certificate{intermediate, root})).NotTo(Succeed())
+		})
+	})
+})
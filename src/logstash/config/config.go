@@ -30,6 +30,7 @@ type Template struct {
 	Groks               []string `yaml:"groks"`
 	Plugins             []string `yaml:"plugins"`
 	ServiceInstanceName string   `yaml:"-"`
+	ClientCertificate   string   `yaml:"-"`
 }
 
 func (c *TemplatesConfig) Parse(data []byte) (err error) {
@@ -44,25 +45,40 @@ func (c *TemplatesConfig) Parse(data []byte) (err error) {
 
 // [APP]Logstash
 type LogstashConfig struct {
-	Set                   bool                `yaml:"-"`
-	Version               string              `yaml:"version"`
-	Plugins               []string            `yaml:"plugins"`
-	Certificates          []string            `yaml:"certificates"`
-	CmdArgs               string              `yaml:"cmd-args"`
-	JavaOpts              string              `yaml:"java-opts"`
-	ReservedMemory        int                 `yaml:"reserved-memory"`
-	HeapPercentage        int                 `yaml:"heap-percentage"`
-	ConfigCheck           bool                `yaml:"config-check"`
-	ConfigTemplates       []ConfigTemplate    `yaml:"config-templates"`
-	EnableServiceFallback bool                `yaml:"enable-service-fallback"`
-	Curator               Curator             `yaml:"curator"`
-	Buildpack             Buildpack           `yaml:"buildpack"`
-	LogstashCredentials   LogstashCredentials `yaml:"logstash-credentials"`
+	Set                   bool                   `yaml:"-"`
+	Version               string                 `yaml:"version"`
+	Plugins               []string               `yaml:"plugins"`
+	Certificates          []string               `yaml:"certificates"`
+	ClientCertificates    []ClientCertificate    `yaml:"client-certificates"`
+	CertificateChecks     CertificateChecks      `yaml:"certificate-checks"`
+	InputTLS              InputTLS               `yaml:"input-tls"`
+	CmdArgs               string                 `yaml:"cmd-args"`
+	JavaOpts              string                 `yaml:"java-opts"`
+	ReservedMemory        int                    `yaml:"reserved-memory"`
+	HeapPercentage        int                    `yaml:"heap-percentage"`
+	Memory                Memory                 `yaml:"memory"`
+	Jvm                   Jvm                    `yaml:"jvm"`
+	Shutdown              Shutdown               `yaml:"shutdown"`
+	HealthCheck           HealthCheck            `yaml:"health-check"`
+	Queue                 Queue                  `yaml:"queue"`
+	DeadLetterQueue       DeadLetterQueue        `yaml:"dead-letter-queue"`
+	Settings              map[string]interface{} `yaml:"settings"`
+	Reload                Reload                 `yaml:"reload"`
+	Logging               Logging                `yaml:"logging"`
+	ConfigCheck           bool                   `yaml:"config-check"`
+	ConfigTemplates       []ConfigTemplate       `yaml:"config-templates"`
+	EnableServiceFallback bool                   `yaml:"enable-service-fallback"`
+	Curator               Curator                `yaml:"curator"`
+	Buildpack             Buildpack              `yaml:"buildpack"`
+	LogstashCredentials   LogstashCredentials    `yaml:"logstash-credentials"`
 }
 
 type LogstashCredentials struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Username            string `yaml:"username"`
+	Password            string `yaml:"password"`
+	ServiceInstanceName string `yaml:"service-instance-name"`
+	UsernameField       string `yaml:"username-field"`
+	PasswordField       string `yaml:"password-field"`
 }
 
 type Buildpack struct {
@@ -75,12 +91,105 @@ type Buildpack struct {
 type ConfigTemplate struct {
 	Name                string `yaml:"name"`
 	ServiceInstanceName string `yaml:"service-instance-name"`
+	ClientCertificate   string `yaml:"client-certificate"`
+}
+
+type CertificateChecks struct {
+	ExpiryWarningDays int  `yaml:"expiry-warning-days"`
+	FailOnExpired     bool `yaml:"fail-on-expired"`
+}
+
+type InputTLS struct {
+	Certificate         string   `yaml:"certificate"`
+	Key                 string   `yaml:"key"`
+	ServiceInstanceName string   `yaml:"service-instance-name"`
+	CertificateField    string   `yaml:"certificate-field"`
+	KeyField            string   `yaml:"key-field"`
+	ClientAuth          string   `yaml:"client-auth"`
+	CipherSuites        []string `yaml:"cipher-suites"`
+	Protocols           []string `yaml:"protocols"`
+}
+
+func (t *InputTLS) Enabled() bool {
+	return t.Certificate != "" || t.ServiceInstanceName != ""
+}
+
+type ClientCertificate struct {
+	Name                string `yaml:"name"`
+	Certificate         string `yaml:"certificate"`
+	Key                 string `yaml:"key"`
+	ServiceInstanceName string `yaml:"service-instance-name"`
+	CertificateField    string `yaml:"certificate-field"`
+	KeyField            string `yaml:"key-field"`
+}
+
+type Memory struct {
+	Workers      int `yaml:"workers"`
+	Threads      int `yaml:"threads"`
+	StackSize    int `yaml:"stack-size"`
+	Metaspace    int `yaml:"metaspace"`
+	DirectMemory int `yaml:"direct-memory"`
+	CodeCache    int `yaml:"code-cache"`
+}
+
+type Jvm struct {
+	GC               string            `yaml:"gc"`
+	GCLogging        bool              `yaml:"gc-logging"`
+	HeapDumpOnOOM    bool              `yaml:"heap-dump-on-oom"`
+	HeapDumpPath     string            `yaml:"heap-dump-path"`
+	SystemProperties map[string]string `yaml:"system-properties"`
+	Options          []string          `yaml:"options"`
+}
+
+type Shutdown struct {
+	DrainTimeout int `yaml:"drain-timeout"`
+}
+
+type Queue struct {
+	Type               string `yaml:"type"`
+	MaxBytes           string `yaml:"max-bytes"`
+	PageCapacity       string `yaml:"page-capacity"`
+	MaxEvents          int    `yaml:"max-events"`
+	CheckpointWrites   int    `yaml:"checkpoint-writes"`
+	CheckpointAcks     int    `yaml:"checkpoint-acks"`
+	CheckpointInterval int    `yaml:"checkpoint-interval"`
+	Path               string `yaml:"path"`
+}
+
+type DeadLetterQueue struct {
+	Enabled    bool   `yaml:"enabled"`
+	MaxBytes   string `yaml:"max-bytes"`
+	Path       string `yaml:"path"`
+	Target     string `yaml:"target"`
+	Index      string `yaml:"index"`
+	PipelineId string `yaml:"pipeline-id"`
+}
+
+type Reload struct {
+	Enabled      bool `yaml:"enabled"`
+	PollInterval int  `yaml:"poll-interval"`
+}
+
+type Logging struct {
+	Format  string            `yaml:"format"`
+	Level   string            `yaml:"level"`
+	Loggers map[string]string `yaml:"loggers"`
+	Slowlog map[string]string `yaml:"slowlog"`
+}
+
+type HealthCheck struct {
+	Enabled      bool   `yaml:"enabled"`
+	Path         string `yaml:"path"`
+	InternalPort int    `yaml:"internal-port"`
+	APIPort      int    `yaml:"api-port"`
+	StallTimeout int    `yaml:"stall-timeout"`
 }
 
 type Curator struct {
 	Set      bool   `yaml:"-"`
 	Install  bool   `yaml:"install"`
 	Schedule string `yaml:"schedule"`
+	Mode     string `yaml:"mode"`
 }
 
 func (c *LogstashConfig) Parse(data []byte) (err error) {
@@ -154,6 +263,18 @@ func (s *VcapServices) WithTags(tags []string) []VcapService {
 	return result
 }
 
+func (s *VcapServices) WithName(name string) (VcapService, bool) {
+	for _, service_instances := range *s {
+		for i := range service_instances {
+			if service_instances[i].Name == name {
+				return service_instances[i], true
+			}
+		}
+	}
+
+	return VcapService{}, false
+}
+
 func (s *VcapServices) UserProvided() []VcapService {
 	result := []VcapService{}
 	for service, service_instances := range *s {
@@ -177,3 +298,35 @@ func (c *VcapServices) Parse(data []byte) (err error) {
 
 	return json.Unmarshal(data, c)
 }
+
+// config.yml in the dependency directory, written at the end of supply. Finalize reads it to create
+// the release step, the launcher prints it in the startup banner.
+type ConfigYml struct {
+	Name   string        `yaml:"name"`
+	Config StagingResult `yaml:"config"`
+}
+
+type StagingResult struct {
+	LogstashVersion  string           `yaml:"LogstashVersion"`
+	OpenJdkVersion   string           `yaml:"OpenJdkVersion"`
+	CuratorInstalled bool             `yaml:"CuratorInstalled"`
+	Templates        []StagedTemplate `yaml:"Templates"`
+	Services         []string         `yaml:"Services"` // service instances bound during staging
+	Plugins          []string         `yaml:"Plugins"`  // plugins of the Logstash file and the templates, e.g. `logstash-filter-translate (3.0.4)`
+	Memory           string           `yaml:"Memory"`   // memory calculation of the staging
+}
+
+type StagedTemplate struct {
+	Name                string `yaml:"Name"`
+	ServiceInstanceName string `yaml:"ServiceInstanceName,omitempty"`
+}
+
+func (c *ConfigYml) Parse(data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("Yaml parsing error: %s", r))
+		}
+	}()
+
+	return yaml.Unmarshal(data, c)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	conf "logstash/config"
 	"logstash/finalize"
 	_ "logstash/hooks"
+	"logstash/redact"
 	"os"
 	"time"
 
@@ -10,7 +12,17 @@ import (
 )
 
 func main() {
-	logger := libbuildpack.NewLogger(os.Stdout)
+	redactor := redact.NewWriter(os.Stdout)
+	logger := libbuildpack.NewLogger(redactor)
+
+	vcapServices := conf.VcapServices{}
+	if err := vcapServices.Parse([]byte(os.Getenv("VCAP_SERVICES"))); err == nil {
+		for _, serviceInstances := range vcapServices {
+			for _, serviceInstance := range serviceInstances {
+				redactor.AddCredentials(serviceInstance.Credentials)
+			}
+		}
+	}
 
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
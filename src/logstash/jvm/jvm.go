@@ -0,0 +1,185 @@
+package jvm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FileName of the JVM options of Logstash in $LOGSTASH_HOME/config
+const FileName = "jvm.options"
+
+// OriginalFileName is the copy of the jvm.options shipped with Logstash, it's the base of every rendering
+const OriginalFileName = "jvm.options.orig"
+
+// garbage collectors which can be selected with `jvm.gc` (JDK 8 flags)
+var garbageCollectors = map[string][]string{
+	"g1":       {"-XX:+UseG1GC"},
+	"cms":      {"-XX:+UseConcMarkSweepGC", "-XX:CMSInitiatingOccupancyFraction=75", "-XX:+UseCMSInitiatingOccupancyOnly"},
+	"parallel": {"-XX:+UseParallelGC"},
+	"serial":   {"-XX:+UseSerialGC"},
+}
+
+// GC logging without -Xloggc goes to stdout, i.e. to the app log
+var gcLoggingFlags = []string{"-XX:+PrintGCDetails", "-XX:+PrintGCDateStamps", "-XX:+PrintGCApplicationStoppedTime"}
+
+// flags which belong to a garbage collector, they are dropped from the original file if a GC is selected
+var gcFlagPattern = regexp.MustCompile(`^(Use\w*GC|CMS\w*|UseCMS\w*|UseParNewGC|G1\w*|ParallelGCThreads|ConcGCThreads)$`)
+
+// flags which select a garbage collector
+var gcSelectPattern = regexp.MustCompile(`^Use\w+GC$`)
+
+var propertyNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// Options are the settings of the `jvm:` section of the Logstash file
+type Options struct {
+	GC               string
+	GCLogging        bool
+	HeapDumpOnOOM    bool
+	HeapDumpPath     string
+	SystemProperties map[string]string
+	Options          []string
+}
+
+// Flags returns the validated JVM flags of the options
+func (o Options) Flags() ([]string, error) {
+	flags := []string{}
+
+	if o.GC != "" {
+		gcFlags, ok := garbageCollectors[strings.ToLower(o.GC)]
+		if !ok {
+			return nil, fmt.Errorf("unknown garbage collector '%s' (g1, cms, parallel or serial)", o.GC)
+		}
+		flags = append(flags, gcFlags...)
+	}
+	if o.GCLogging {
+		flags = append(flags, gcLoggingFlags...)
+	}
+	if o.HeapDumpOnOOM {
+		flags = append(flags, "-XX:+HeapDumpOnOutOfMemoryError")
+	}
+	if o.HeapDumpPath != "" {
+		flags = append(flags, "-XX:HeapDumpPath="+o.HeapDumpPath)
+	}
+
+	names := make([]string, 0, len(o.SystemProperties))
+	for name := range o.SystemProperties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !propertyNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid system property name '%s'", name)
+		}
+		flags = append(flags, fmt.Sprintf("-D%s=%s", name, o.SystemProperties[name]))
+	}
+
+	for _, option := range o.Options {
+		if !strings.HasPrefix(option, "-") {
+			return nil, fmt.Errorf("JVM option '%s' doesn't start with '-'", option)
+		}
+		flags = append(flags, option)
+	}
+
+	//Logstash splits the options of jvm.options at whitespace
+	for _, flag := range flags {
+		if strings.IndexFunc(flag, isSpace) >= 0 {
+			return nil, fmt.Errorf("JVM option '%s' must not contain whitespace", flag)
+		}
+	}
+
+	return flags, nil
+}
+
+// Merge returns the original jvm.options without the lines overridden by flags, followed by the flags
+func Merge(original []byte, flags []string) []byte {
+	overridden := make(map[string]bool)
+	selectsGC := false
+	for _, flag := range flags {
+		key := Key(flag)
+		overridden[key] = true
+		if gcSelectPattern.MatchString(key) {
+			selectsGC = true
+		}
+	}
+
+	var buffer bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(original))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "-") || versionPrefix.MatchString(trimmed) {
+			key := Key(trimmed)
+			if overridden[key] || (selectsGC && gcFlagPattern.MatchString(key)) {
+				buffer.WriteString("# replaced by the buildpack: " + trimmed + "\n")
+				continue
+			}
+		}
+		buffer.WriteString(line + "\n")
+	}
+
+	buffer.WriteString("\n## generated by the buildpack\n")
+	for _, flag := range flags {
+		buffer.WriteString(flag + "\n")
+	}
+	return buffer.Bytes()
+}
+
+// Render writes $LOGSTASH_HOME/config/jvm.options, the original file shipped with Logstash is
+// kept as jvm.options.orig (missing files are treated as empty)
+func Render(logstashHome string, flags []string) error {
+	configDir := filepath.Join(logstashHome, "config")
+	originalFile := filepath.Join(configDir, OriginalFileName)
+
+	original, err := ioutil.ReadFile(originalFile)
+	if os.IsNotExist(err) {
+		original, err = ioutil.ReadFile(filepath.Join(configDir, FileName))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(originalFile, original, 0644); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(configDir, FileName), Merge(original, flags), 0644)
+}
+
+// jvm.options lines may be restricted to JDK versions, e.g. `8:-XX:+UseConcMarkSweepGC` or `9-:-Xlog:gc`
+var versionPrefix = regexp.MustCompile(`^\d+(-\d*)?:`)
+
+// Key returns the part of a flag which identifies it, e.g. `Xmx` for `-Xmx1g`, `UseG1GC` for `-XX:+UseG1GC`
+// and `Dfile.encoding` for `-Dfile.encoding=UTF-8`
+func Key(flag string) string {
+	flag = strings.TrimPrefix(versionPrefix.ReplaceAllString(flag, ""), "-")
+
+	switch {
+	case strings.HasPrefix(flag, "XX:"):
+		flag = strings.TrimLeft(strings.TrimPrefix(flag, "XX:"), "+-")
+		return strings.SplitN(flag, "=", 2)[0]
+	case strings.HasPrefix(flag, "D"):
+		return strings.SplitN(flag, "=", 2)[0]
+	}
+
+	for _, prefix := range []string{"Xmx", "Xms", "Xss", "Xmn", "Xloggc"} {
+		if strings.HasPrefix(flag, prefix) {
+			return prefix
+		}
+	}
+	return flag
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,13 @@
+package jvm_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestJvm(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Jvm Suite")
+}
@@ -0,0 +1,108 @@
+package jvm_test
+
+import (
+	"logstash/jvm"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jvm", func() {
+	Describe("Flags", func() {
+		It("returns the flags of all settings", func() {
+			options := jvm.Options{
+				GC:               "G1",
+				GCLogging:        true,
+				HeapDumpOnOOM:    true,
+				HeapDumpPath:     "/home/vcap/tmp",
+				SystemProperties: map[string]string{"b.prop": "2", "a.prop": "1"},
+				Options:          []string{"-XX:+ExitOnOutOfMemoryError"},
+			}
+			flags, err := options.Flags()
+			Expect(err).To(BeNil())
+			Expect(flags).To(Equal([]string{
+				"-XX:+UseG1GC",
+				"-XX:+PrintGCDetails", "-XX:+PrintGCDateStamps", "-XX:+PrintGCApplicationStoppedTime",
+				"-XX:+HeapDumpOnOutOfMemoryError",
+				"-XX:HeapDumpPath=/home/vcap/tmp",
+				"-Da.prop=1",
+				"-Db.prop=2",
+				"-XX:+ExitOnOutOfMemoryError",
+			}))
+		})
+
+		It("returns no flags without settings", func() {
+			flags, err := jvm.Options{}.Flags()
+			Expect(err).To(BeNil())
+			Expect(flags).To(BeEmpty())
+		})
+
+		It("refuses unknown garbage collectors", func() {
+			_, err := jvm.Options{GC: "zgc"}.Flags()
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("refuses options with whitespace or without dash", func() {
+			_, err := jvm.Options{SystemProperties: map[string]string{"greeting": "hello world"}}.Flags()
+			Expect(err).NotTo(BeNil())
+
+			_, err = jvm.Options{Options: []string{"Xmx1g"}}.Flags()
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("refuses invalid property names", func() {
+			_, err := jvm.Options{SystemProperties: map[string]string{"a=b": "c"}}.Flags()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("Key", func() {
+		It("identifies flags", func() {
+			Expect(jvm.Key("-Xmx1g")).To(Equal("Xmx"))
+			Expect(jvm.Key("-Xss2048k")).To(Equal("Xss"))
+			Expect(jvm.Key("-XX:+UseG1GC")).To(Equal("UseG1GC"))
+			Expect(jvm.Key("-XX:-UseG1GC")).To(Equal("UseG1GC"))
+			Expect(jvm.Key("-XX:MaxMetaspaceSize=128m")).To(Equal("MaxMetaspaceSize"))
+			Expect(jvm.Key("-Dfile.encoding=UTF-8")).To(Equal("Dfile.encoding"))
+			Expect(jvm.Key("8:-XX:+UseConcMarkSweepGC")).To(Equal("UseConcMarkSweepGC"))
+		})
+	})
+
+	Describe("Merge", func() {
+		original := []byte(`## JVM configuration
+-Xms1g
+-Xmx1g
+-XX:+UseParNewGC
+-XX:+UseConcMarkSweepGC
+-XX:CMSInitiatingOccupancyFraction=75
+-Djava.awt.headless=true
+#-XX:HeapDumpPath=${LOGSTASH_HOME}/heapdump.hprof
+`)
+
+		It("replaces overridden flags and appends the flags", func() {
+			merged := string(jvm.Merge(original, []string{"-Xmx512m", "-Xms512m", "-Djava.awt.headless=false"}))
+			Expect(merged).To(Equal(`## JVM configuration
+# replaced by the buildpack: -Xms1g
+# replaced by the buildpack: -Xmx1g
+-XX:+UseParNewGC
+-XX:+UseConcMarkSweepGC
+-XX:CMSInitiatingOccupancyFraction=75
+# replaced by the buildpack: -Djava.awt.headless=true
+#-XX:HeapDumpPath=${LOGSTASH_HOME}/heapdump.hprof
+
+## generated by the buildpack
+-Xmx512m
+-Xms512m
+-Djava.awt.headless=false
+`))
+		})
+
+		It("drops the flags of the original garbage collector", func() {
+			merged := string(jvm.Merge(original, []string{"-XX:+UseG1GC"}))
+			Expect(merged).To(ContainSubstring("# replaced by the buildpack: -XX:+UseParNewGC\n"))
+			Expect(merged).To(ContainSubstring("# replaced by the buildpack: -XX:+UseConcMarkSweepGC\n"))
+			Expect(merged).To(ContainSubstring("# replaced by the buildpack: -XX:CMSInitiatingOccupancyFraction=75\n"))
+			Expect(merged).To(ContainSubstring("\n-Xmx1g\n"))
+		})
+	})
+})
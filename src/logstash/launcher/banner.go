@@ -0,0 +1,152 @@
+package launcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	conf "logstash/config"
+	"logstash/memory"
+)
+
+// formats of the startup banner (LS_BANNER_FORMAT)
+const (
+	BannerFormatText = "text"
+	BannerFormatJSON = "json"
+	BannerFormatNone = "none"
+)
+
+// Banner is the effective configuration of an instance, it's logged at startup so an instance can
+// be diagnosed from `cf logs` alone. The staging results are read from config.yml.
+type Banner struct {
+	Logstash      string   `json:"logstash"`
+	OpenJdk       string   `json:"openjdk"`
+	Instance      int      `json:"instance"`
+	Memory        string   `json:"memory"`
+	Templates     []string `json:"templates"`
+	Services      []string `json:"services"`
+	Plugins       []string `json:"plugins"`
+	PipelineFiles []string `json:"pipeline_files"`
+	GrokPatterns  []string `json:"grok_patterns"`
+	Features      []string `json:"features"`
+}
+
+// Banner collects the staging results and the rendered configuration, call it after Prepare
+func (l *Launcher) Banner() Banner {
+	banner := Banner{
+		Instance:  l.Config.InstanceIndex,
+		Templates: []string{},
+		Features:  []string{},
+	}
+
+	configYml := conf.ConfigYml{}
+	data, err := ioutil.ReadFile(filepath.Join(l.Config.Root, "config.yml"))
+	if err == nil {
+		err = configYml.Parse(data)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		l.Log.Warning("unable to read the staging results: %s", err.Error())
+	}
+	staging := configYml.Config
+
+	banner.Logstash = staging.LogstashVersion
+	banner.OpenJdk = staging.OpenJdkVersion
+	banner.Services = nonNil(staging.Services)
+	banner.Plugins = nonNil(staging.Plugins)
+	for _, template := range staging.Templates {
+		name := template.Name
+		if template.ServiceInstanceName != "" {
+			name = fmt.Sprintf("%s (%s)", name, template.ServiceInstanceName)
+		}
+		banner.Templates = append(banner.Templates, name)
+	}
+
+	banner.Memory = staging.Memory
+	if l.Config.MemoryLimit > 0 {
+		if result, err := memory.Calculate(l.MemorySettings()); err == nil {
+			banner.Memory = result.String()
+		}
+	}
+
+	banner.PipelineFiles = fileNames(filepath.Join(l.Config.Home, "logstash.conf.d"))
+	banner.GrokPatterns = fileNames(filepath.Join(l.Config.Home, "grok-patterns"))
+
+	for feature, enabled := range map[string]bool{
+		"health-check": l.Config.HealthCheck.Enabled,
+		"reload":       l.Config.Reload,
+		"curator":      l.Config.SchedulesCurator(),
+	} {
+		if enabled {
+			banner.Features = append(banner.Features, feature)
+		}
+	}
+	sort.Strings(banner.Features)
+	return banner
+}
+
+// PrintBanner logs the banner in the format of LS_BANNER_FORMAT, json is written as a single line
+func (l *Launcher) PrintBanner() {
+	if l.Config.BannerFormat == BannerFormatNone {
+		return
+	}
+	banner := l.Banner()
+
+	if l.Config.BannerFormat == BannerFormatJSON {
+		data, err := json.Marshal(map[string]Banner{"startup_banner": banner})
+		if err != nil {
+			l.Log.Warning("unable to print the startup banner: %s", err.Error())
+			return
+		}
+		fmt.Fprintln(l.Stdout, l.Redact(string(data)))
+		return
+	}
+
+	logstash := banner.Logstash
+	if banner.OpenJdk != "" {
+		logstash = fmt.Sprintf("%s (OpenJDK %s)", logstash, banner.OpenJdk)
+	}
+	l.Log.Info("STARTUP BANNER")
+	for _, line := range [][2]string{
+		{"Logstash", logstash},
+		{"Instance", fmt.Sprintf("%d", banner.Instance)},
+		{"Memory", banner.Memory},
+		{"Templates", strings.Join(banner.Templates, ", ")},
+		{"Services", strings.Join(banner.Services, ", ")},
+		{"Plugins", strings.Join(banner.Plugins, ", ")},
+		{"Pipeline files", strings.Join(banner.PipelineFiles, ", ")},
+		{"Grok patterns", strings.Join(banner.GrokPatterns, ", ")},
+		{"Features", strings.Join(banner.Features, ", ")},
+	} {
+		value := line[1]
+		if value == "" {
+			value = "none"
+		}
+		l.Log.Info("  %-15s %s", line[0]+":", l.Redact(value))
+	}
+}
+
+// fileNames returns the sorted names of the files in dir
+func fileNames(dir string) []string {
+	names := []string{}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+	for _, file := range files {
+		if !file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	return names
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
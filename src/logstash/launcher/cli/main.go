@@ -0,0 +1,65 @@
+package main
+
+import (
+	conf "logstash/config"
+	"logstash/launcher"
+	"logstash/redact"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andibrunner/libbuildpack"
+)
+
+func main() {
+	redactor := redact.NewWriter(os.Stdout)
+	logger := libbuildpack.NewLogger(redactor)
+
+	//the passwords of key- and truststores are part of the Java options
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[0], "LS_") && strings.HasSuffix(parts[0], "_PASSWORD") {
+			redactor.AddSecret(parts[1])
+		}
+	}
+	//secrets of the Logstash file, e.g. the static password of the http input
+	if err := redactor.AddSecretsFile(filepath.Join(os.Getenv("LS_ROOT"), redact.SecretsFileName)); err != nil && !os.IsNotExist(err) {
+		logger.Warning("Unable to read the secrets to redact: %s", err.Error())
+	}
+	vcapServices := conf.VcapServices{}
+	if err := vcapServices.Parse([]byte(os.Getenv("VCAP_SERVICES"))); err == nil {
+		for _, serviceInstances := range vcapServices {
+			for _, serviceInstance := range serviceInstances {
+				redactor.AddCredentials(serviceInstance.Credentials)
+			}
+		}
+	}
+
+	config, err := launcher.ConfigFromEnv(os.Getenv)
+	if err != nil {
+		logger.Error("Invalid runtime environment: %s", err.Error())
+		os.Exit(2)
+	}
+
+	l := launcher.NewLauncher(config, logger)
+	l.Redact = redactor.Redact
+	//the output of Logstash, gte, Curator and Ofelia may contain secrets as well
+	stdout := redactor.NewLineWriter(os.Stdout)
+	stderr := redactor.NewLineWriter(os.Stderr)
+	l.Stdout = stdout
+	l.Stderr = stderr
+	processType := ""
+	if len(os.Args) > 1 {
+		processType = os.Args[1]
+	}
+	code, err := launcher.RunProcess(l, processType)
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		logger.Error("Logstash startup failed: %s", err.Error())
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,247 @@
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	conf "logstash/config"
+	"logstash/memory"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the runtime settings written to the profile.d scripts during staging
+type Config struct {
+	Home                  string // $HOME, the app directory
+	Root                  string // $LS_ROOT, the buildpack dependency directory
+	LogstashHome          string
+	GteHome               string
+	OfeliaHome            string
+	JavaOpts              string // user defined java-opts (LS_BP_JAVA_OPTS)
+	ReservedMemory        int
+	HeapPercentage        int
+	Workers               int
+	Threads               int
+	StackSize             int // KB
+	Metaspace             int
+	DirectMemory          int
+	CodeCache             int
+	TrustStoreOpts        string
+	CmdArgs               string
+	CuratorEnabled        bool
+	CuratorMode           string // daemon, leader or task (curator.mode)
+	InstanceIndex         int    // $CF_INSTANCE_INDEX
+	BannerFormat          string // text, json or none (LS_BANNER_FORMAT)
+	Maintenance           bool   // LS_MAINTENANCE_MODE or buildpack.sleep-command (LS_DO_SLEEP)
+	CertExpiryWarningDays int
+	DrainTimeout          time.Duration // time Logstash gets to shut down after SIGTERM
+	MemoryLimit           int           // container memory limit in MB (VCAP_APPLICATION)
+	Port                  int           // $PORT, the port of the app
+	Reload                bool          // re-render conf.d on SIGHUP, Logstash reloads the changes
+	ReloadPollInterval    time.Duration // re-render conf.d in this interval, 0 disables it
+	HealthCheck           HealthCheckConfig
+}
+
+// HealthCheckConfig holds the settings of the `health-check:` section
+type HealthCheckConfig struct {
+	Enabled      bool
+	Path         string
+	InternalPort int // port of the Logstash inputs, $PORT is served by the launcher
+	APIPort      int // port of the Logstash monitoring API
+	StallTimeout time.Duration
+}
+
+// ConfigFromEnv reads the launcher configuration from the environment
+func ConfigFromEnv(getenv func(string) string) (Config, error) {
+	config := Config{
+		Home:           getenv("HOME"),
+		Root:           getenv("LS_ROOT"),
+		LogstashHome:   getenv("LOGSTASH_HOME"),
+		GteHome:        getenv("GTE_HOME"),
+		OfeliaHome:     getenv("OFELIA_HOME"),
+		JavaOpts:       getenv("LS_BP_JAVA_OPTS"),
+		TrustStoreOpts: getenv("LS_TRUSTSTORE_OPTS"),
+		CmdArgs:        getenv("LS_CMD_ARGS"),
+		CuratorEnabled: getenv("LS_CURATOR_ENABLED") != "",
+		CuratorMode:    getenv("LS_CURATOR_MODE"),
+		BannerFormat:   strings.ToLower(getenv("LS_BANNER_FORMAT")),
+		Maintenance:    isEnabled(getenv("LS_MAINTENANCE_MODE")) || getenv("LS_DO_SLEEP") != "",
+	}
+
+	for _, name := range []string{"HOME", "LS_ROOT", "LOGSTASH_HOME", "GTE_HOME"} {
+		if getenv(name) == "" {
+			return config, fmt.Errorf("%s is not set", name)
+		}
+	}
+	if config.CuratorEnabled && config.OfeliaHome == "" {
+		return config, errors.New("OFELIA_HOME is not set")
+	}
+	switch config.CuratorMode {
+	case "":
+		config.CuratorMode = CuratorModeDaemon
+	case CuratorModeDaemon, CuratorModeLeader, CuratorModeTask:
+	default:
+		return config, fmt.Errorf("invalid LS_CURATOR_MODE '%s'", config.CuratorMode)
+	}
+
+	switch config.BannerFormat {
+	case "":
+		config.BannerFormat = BannerFormatText
+	case BannerFormatText, BannerFormatJSON, BannerFormatNone:
+	default:
+		return config, fmt.Errorf("invalid LS_BANNER_FORMAT '%s' (text, json or none)", config.BannerFormat)
+	}
+
+	var err error
+	if config.ReservedMemory, err = intFromEnv(getenv, "LS_BP_RESERVED_MEMORY", 0); err != nil {
+		return config, err
+	}
+	if config.HeapPercentage, err = intFromEnv(getenv, "LS_BP_HEAP_PERCENTAGE", 0); err != nil {
+		return config, err
+	}
+	if config.CertExpiryWarningDays, err = intFromEnv(getenv, "LS_CERT_EXPIRY_WARNING_DAYS", 30); err != nil {
+		return config, err
+	}
+
+	drainTimeout, err := intFromEnv(getenv, "LS_BP_DRAIN_TIMEOUT", 0)
+	if err != nil {
+		return config, err
+	}
+	config.DrainTimeout = DefaultDrainTimeout
+	if drainTimeout > 0 {
+		config.DrainTimeout = time.Duration(drainTimeout) * time.Second
+	}
+
+	//memory calculator settings, 0 selects the default
+	for name, value := range map[string]*int{
+		"LS_BP_WORKERS":       &config.Workers,
+		"LS_BP_THREADS":       &config.Threads,
+		"LS_BP_STACK_SIZE":    &config.StackSize,
+		"LS_BP_METASPACE":     &config.Metaspace,
+		"LS_BP_DIRECT_MEMORY": &config.DirectMemory,
+		"LS_BP_CODE_CACHE":    &config.CodeCache,
+	} {
+		if *value, err = intFromEnv(getenv, name, 0); err != nil {
+			return config, err
+		}
+	}
+
+	if getenv("LS_BP_RELOAD") != "" {
+		config.Reload = true
+		pollInterval, err := intFromEnv(getenv, "LS_BP_RELOAD_POLL_INTERVAL", 0)
+		if err != nil {
+			return config, err
+		}
+		config.ReloadPollInterval = time.Duration(pollInterval) * time.Second
+	}
+
+	if config.InstanceIndex, err = intFromEnv(getenv, "CF_INSTANCE_INDEX", 0); err != nil {
+		return config, err
+	}
+
+	if config.Port, err = intFromEnv(getenv, "PORT", 0); err != nil {
+		return config, err
+	}
+
+	if getenv("LS_BP_HEALTH_CHECK") != "" {
+		if config.HealthCheck, err = healthCheckFromEnv(getenv); err != nil {
+			return config, err
+		}
+		if config.Port == 0 {
+			return config, errors.New("PORT is not set, it's required by the health check")
+		}
+	}
+
+	if vcapApplication := getenv("VCAP_APPLICATION"); vcapApplication != "" {
+		app := conf.VcapApp{}
+		if err := app.Parse([]byte(vcapApplication)); err != nil {
+			return config, fmt.Errorf("unable to parse VCAP_APPLICATION: %s", err.Error())
+		}
+		if app.Limits != nil {
+			config.MemoryLimit = app.Limits.Mem
+		}
+	}
+
+	return config, nil
+}
+
+// modes of LS_CURATOR_MODE (curator.mode)
+const (
+	CuratorModeDaemon = "daemon" // every instance schedules Curator
+	CuratorModeLeader = "leader" // only the instance with CF_INSTANCE_INDEX 0 schedules Curator
+	CuratorModeTask   = "task"   // the Logstash instances don't schedule Curator
+)
+
+// SchedulesCurator returns whether this Logstash instance runs Curator and Ofelia
+func (c Config) SchedulesCurator() bool {
+	if !c.CuratorEnabled {
+		return false
+	}
+	switch c.CuratorMode {
+	case CuratorModeLeader:
+		return c.InstanceIndex == 0
+	case CuratorModeTask:
+		return false
+	}
+	return true
+}
+
+// MemorySettings returns the input of the memory calculation, the workers are `memory.workers`
+// (see Launcher.MemorySettings for the defaults)
+func (c Config) MemorySettings() memory.Settings {
+	settings := memory.Settings{
+		Limit:          c.MemoryLimit,
+		Reserved:       c.ReservedMemory,
+		HeapPercentage: c.HeapPercentage,
+		Workers:        c.Workers,
+		Threads:        c.Threads,
+		StackSize:      c.StackSize,
+		Metaspace:      c.Metaspace,
+		DirectMemory:   c.DirectMemory,
+		CodeCache:      c.CodeCache,
+	}
+	return settings
+}
+
+func healthCheckFromEnv(getenv func(string) string) (HealthCheckConfig, error) {
+	healthCheck := HealthCheckConfig{Enabled: true, Path: getenv("LS_BP_HEALTH_CHECK_PATH")}
+	if healthCheck.Path == "" {
+		return healthCheck, errors.New("LS_BP_HEALTH_CHECK_PATH is not set")
+	}
+
+	var err error
+	if healthCheck.InternalPort, err = intFromEnv(getenv, "LS_BP_HEALTH_CHECK_INTERNAL_PORT", 0); err != nil {
+		return healthCheck, err
+	}
+	if healthCheck.APIPort, err = intFromEnv(getenv, "LS_BP_HEALTH_CHECK_API_PORT", 0); err != nil {
+		return healthCheck, err
+	}
+	if healthCheck.InternalPort == 0 || healthCheck.APIPort == 0 {
+		return healthCheck, errors.New("LS_BP_HEALTH_CHECK_INTERNAL_PORT and LS_BP_HEALTH_CHECK_API_PORT are required")
+	}
+
+	stallTimeout, err := intFromEnv(getenv, "LS_BP_HEALTH_CHECK_STALL_TIMEOUT", 0)
+	if err != nil {
+		return healthCheck, err
+	}
+	healthCheck.StallTimeout = time.Duration(stallTimeout) * time.Second
+	return healthCheck, nil
+}
+
+// isEnabled returns whether a switch set with `cf set-env` is on, "false" and "0" turn it off
+func isEnabled(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	return value != "" && value != "false" && value != "0" && value != "no" && value != "off"
+}
+
+func intFromEnv(getenv func(string) string, name string, defaultValue int) (int, error) {
+	value := getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number: '%s'", name, value)
+	}
+	return i, nil
+}
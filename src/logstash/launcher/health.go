@@ -0,0 +1,176 @@
+package launcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Health is the state reported by the health endpoint
+type Health struct {
+	Healthy     bool              `json:"healthy"`
+	Maintenance bool              `json:"maintenance,omitempty"`
+	Reasons     []string          `json:"reasons,omitempty"`
+	Pipelines   map[string]string `json:"pipelines,omitempty"`
+	CheckedAt   time.Time         `json:"checked_at"`
+}
+
+// nodeStats is the part of the response of /_node/stats/pipelines evaluated by the health check
+type nodeStats struct {
+	Pipelines map[string]struct {
+		Events struct {
+			In       int64 `json:"in"`
+			Filtered int64 `json:"filtered"`
+			Out      int64 `json:"out"`
+		} `json:"events"`
+		Queue struct {
+			Type        string `json:"type"`
+			Events      int64  `json:"events"`       // persisted queue of Logstash 6
+			EventsCount int64  `json:"events_count"` // persisted queue of later versions
+			Capacity    struct {
+				QueueSizeInBytes    int64 `json:"queue_size_in_bytes"`
+				MaxQueueSizeInBytes int64 `json:"max_queue_size_in_bytes"`
+			} `json:"capacity"`
+		} `json:"queue"`
+	} `json:"pipelines"`
+}
+
+type pipelineProgress struct {
+	filtered int64
+	out      int64
+	movedAt  time.Time
+}
+
+// HealthChecker evaluates the node stats API of Logstash. Logstash is healthy if at least one
+// pipeline is running, no pipeline has events waiting (received but not filtered yet, or in the
+// persisted queue) without any filter or output progress for StallTimeout and no persisted queue
+// is full. Events removed by filters (e.g. drop) are filtered but never output, so in and out
+// aren't compared.
+type HealthChecker struct {
+	StatsURL     string
+	StallTimeout time.Duration
+	Client       *http.Client
+
+	mutex    sync.Mutex
+	health   Health
+	progress map[string]pipelineProgress
+}
+
+func NewHealthChecker(apiPort int, stallTimeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		StatsURL:     fmt.Sprintf("http://127.0.0.1:%d/_node/stats/pipelines", apiPort),
+		StallTimeout: stallTimeout,
+		Client:       &http.Client{Timeout: 5 * time.Second},
+		health:       Health{Reasons: []string{"Logstash not checked yet"}},
+		progress:     make(map[string]pipelineProgress),
+	}
+}
+
+// Run checks the health every interval until stop is closed
+func (h *HealthChecker) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.Check(time.Now())
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health returns the result of the last check
+func (h *HealthChecker) Health() Health {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.health
+}
+
+// Check queries the node stats of Logstash and updates the health
+func (h *HealthChecker) Check(now time.Time) Health {
+	health := Health{CheckedAt: now.UTC()}
+
+	stats, err := h.nodeStats()
+	if err != nil {
+		health.Reasons = []string{fmt.Sprintf("node stats unavailable: %s", err.Error())}
+	} else {
+		health = h.evaluate(stats, now)
+	}
+
+	h.mutex.Lock()
+	h.health = health
+	h.mutex.Unlock()
+	return health
+}
+
+func (h *HealthChecker) nodeStats() (nodeStats, error) {
+	stats := nodeStats{}
+
+	resp, err := h.Client.Get(h.StatsURL)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return stats, err
+	}
+	return stats, json.Unmarshal(data, &stats)
+}
+
+func (h *HealthChecker) evaluate(stats nodeStats, now time.Time) Health {
+	health := Health{CheckedAt: now.UTC(), Pipelines: make(map[string]string)}
+
+	if len(stats.Pipelines) == 0 {
+		health.Reasons = append(health.Reasons, "no pipeline running")
+	}
+
+	names := make([]string, 0, len(stats.Pipelines))
+	for name := range stats.Pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, name := range names {
+		pipeline := stats.Pipelines[name]
+		state := "running"
+
+		waiting := pipeline.Events.In - pipeline.Events.Filtered
+		if queued := pipeline.Queue.Events + pipeline.Queue.EventsCount; queued > waiting {
+			waiting = queued
+		}
+
+		progress, ok := h.progress[name]
+		if !ok || pipeline.Events.Filtered != progress.filtered || pipeline.Events.Out != progress.out || waiting <= 0 {
+			progress = pipelineProgress{filtered: pipeline.Events.Filtered, out: pipeline.Events.Out, movedAt: now}
+			h.progress[name] = progress
+		}
+		if stalled := now.Sub(progress.movedAt); stalled >= h.StallTimeout {
+			state = "stalled"
+			health.Reasons = append(health.Reasons, fmt.Sprintf("pipeline %s: %d events waiting, no progress for %s", name, waiting, stalled.Round(time.Second)))
+		}
+
+		capacity := pipeline.Queue.Capacity
+		if pipeline.Queue.Type == "persisted" && capacity.MaxQueueSizeInBytes > 0 && capacity.QueueSizeInBytes >= capacity.MaxQueueSizeInBytes {
+			state = "queue full"
+			health.Reasons = append(health.Reasons, fmt.Sprintf("pipeline %s: persisted queue is full (%d bytes)", name, capacity.QueueSizeInBytes))
+		}
+
+		health.Pipelines[name] = state
+	}
+
+	health.Healthy = len(health.Reasons) == 0
+	return health
+}
@@ -0,0 +1,464 @@
+package launcher
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"logstash/certificates"
+	"logstash/jvm"
+	"logstash/memory"
+	"logstash/settings"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andibrunner/libbuildpack"
+	"gopkg.in/yaml.v2"
+)
+
+// pipelinesFileName is the pipelines configuration of Logstash in $LOGSTASH_HOME/config, the
+// pipelines.yml of the app is rendered into it
+const pipelinesFileName = "pipelines.yml"
+
+// Launcher prepares the runtime environment of the app and runs Logstash and Curator
+type Launcher struct {
+	Config Config
+	Log    *libbuildpack.Logger
+	Stdout io.Writer
+	Stderr io.Writer
+	Redact func(string) string // removes secrets from the diagnostics of the maintenance mode
+}
+
+func NewLauncher(config Config, logger *libbuildpack.Logger) *Launcher {
+	return &Launcher{
+		Config: config,
+		Log:    logger,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Redact: func(text string) string { return text },
+	}
+}
+
+// Run starts up Logstash and returns its exit code once it terminated
+func Run(l *Launcher) (int, error) {
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	l.Log.Info("STARTING UP ...")
+
+	if l.Config.Maintenance {
+		return RunMaintenance(l, signals)
+	}
+
+	if l.Config.HealthCheck.Enabled {
+		proxy, err := l.StartHealthCheck()
+		if err != nil {
+			return 1, err
+		}
+		defer proxy.Close()
+	}
+
+	if err := l.Prepare(); err != nil {
+		return 1, err
+	}
+
+	//stopped while preparing
+	select {
+	case sig := <-signals:
+		if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+			l.Log.Info("received %s during startup, Logstash is not started", sig)
+			return 0, nil
+		}
+	default:
+	}
+
+	supervisor := NewSupervisor(l.Log, signals)
+	if l.Config.DrainTimeout > 0 {
+		supervisor.DrainTimeout = l.Config.DrainTimeout
+	}
+	if l.Config.Reload {
+		reloader := NewReloader(l)
+		supervisor.OnHangup = reloader.Hangup
+		if l.Config.ReloadPollInterval > 0 {
+			stop := make(chan struct{})
+			defer close(stop)
+			go reloader.Poll(l.Config.ReloadPollInterval, stop)
+		}
+		l.Log.Info("automatic reload enabled, send SIGHUP to re-render the pipeline templates")
+	}
+
+	if l.Config.CuratorEnabled && !l.Config.SchedulesCurator() {
+		l.Log.Info("Curator is not scheduled by this instance (curator.mode: %s, instance %d)", l.Config.CuratorMode, l.Config.InstanceIndex)
+	}
+	if l.Config.SchedulesCurator() {
+		l.Log.Info("running Curator once to create the Logstash index for today")
+		if err := l.command(filepath.Join(l.Config.Home, "bin", "curator.sh")).Run(); err != nil {
+			l.Log.Warning("Curator failed: %s", err.Error())
+		}
+
+		l.Log.Info("starting Ofelia for Curator")
+		ofelia := l.command(filepath.Join(l.Config.OfeliaHome, "ofelia"), "daemon", "--config", filepath.Join(l.Config.Home, "ofelia", "schedule.ini"))
+		ofelia.Stderr = l.Stdout
+		if err := supervisor.StartHelper("Ofelia", ofelia); err != nil {
+			return 1, err
+		}
+	}
+
+	l.PrintBanner()
+
+	l.Log.Info("STARTING LOGSTASH ...")
+	if l.Config.CmdArgs != "" {
+		l.Log.Info("Using LS_CMD_ARGS=\"%s\"", l.Config.CmdArgs)
+	}
+	args, err := l.LogstashArgs()
+	if err != nil {
+		supervisor.Stop()
+		return 1, err
+	}
+	if l.Config.Reload {
+		//only the main run, config.reload.automatic can't be combined with -t
+		args = append(args, "--config.reload.automatic")
+	}
+	if err := supervisor.StartMain("Logstash", l.command(filepath.Join(l.Config.LogstashHome, "bin", "logstash"), args...)); err != nil {
+		supervisor.Stop()
+		return 1, err
+	}
+
+	return supervisor.Wait()
+}
+
+// health of Logstash is polled in this interval, the health endpoint returns the last result
+const healthCheckInterval = 5 * time.Second
+
+// StartHealthCheck serves the health endpoint on $PORT and forwards all other connections to the
+// internal port. PORT is set to the internal port for the templates and Logstash.
+func (l *Launcher) StartHealthCheck() (*HealthProxy, error) {
+	healthCheck := l.Config.HealthCheck
+
+	checker := NewHealthChecker(healthCheck.APIPort, healthCheck.StallTimeout)
+	proxy := NewHealthProxy(healthCheck.Path, healthCheck.InternalPort, checker.Health, l.Log)
+	if err := proxy.Listen(fmt.Sprintf(":%d", l.Config.Port)); err != nil {
+		return nil, fmt.Errorf("unable to listen on port %d for the health check: %s", l.Config.Port, err.Error())
+	}
+	if err := os.Setenv("PORT", fmt.Sprintf("%d", healthCheck.InternalPort)); err != nil {
+		proxy.Close()
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	go checker.Run(healthCheckInterval, stop)
+	go func() {
+		proxy.Serve()
+		close(stop)
+	}()
+
+	l.Log.Info("health check on port %d%s, Logstash inputs listen on port %d", l.Config.Port, healthCheck.Path, healthCheck.InternalPort)
+	return proxy, nil
+}
+
+// Prepare renders jvm.options, sets the Java options, renders the templates and checks the certificates
+func (l *Launcher) Prepare() error {
+	l.Log.Info("container memory limit = %dm", l.Config.MemoryLimit)
+
+	javaOpts := l.Config.JavaOpts
+	if javaOpts != "" {
+		l.Log.Info("Using LS_JAVA_OPTS=\"%s\" (java-opts, added to jvm.options)", javaOpts)
+	}
+	if l.Config.TrustStoreOpts != "" {
+		l.Log.Info("Using TrustStore %s", os.Getenv("LS_TRUSTSTORE"))
+		javaOpts = strings.TrimSpace(javaOpts + " " + l.Config.TrustStoreOpts)
+	}
+	if err := os.Setenv("LS_JAVA_OPTS", javaOpts); err != nil {
+		return err
+	}
+
+	l.Log.Info("preparing runtime directories ...")
+	if err := l.PrepareDirectories(); err != nil {
+		return fmt.Errorf("unable to prepare runtime directories: %s", err.Error())
+	}
+
+	l.Log.Info("template processing ...")
+	if err := l.RenderTemplates(); err != nil {
+		return err
+	}
+
+	if err := l.RenderSettings(); err != nil {
+		return fmt.Errorf("unable to render logstash.yml: %s", err.Error())
+	}
+
+	//the memory calculation uses the pipeline.workers of the rendered logstash.yml
+	if err := l.RenderJvmOptions(); err != nil {
+		return err
+	}
+
+	l.Log.Info("checking certificates ...")
+	l.CheckCertificates(time.Now())
+
+	return nil
+}
+
+// MemoryFlags returns the JVM options of the memory calculation, none if the memory limit is unknown
+func (l *Launcher) MemoryFlags() ([]string, *memory.Result, error) {
+	if l.Config.MemoryLimit == 0 {
+		l.Log.Warning("memory limit is unknown, using the memory settings of jvm.options")
+		return nil, nil, nil
+	}
+
+	result, err := memory.Calculate(l.MemorySettings())
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.JavaOpts(), &result, nil
+}
+
+// MemorySettings returns the input of the memory calculation. Without `memory.workers` the
+// workers are the `pipeline.workers` of the rendered logstash.yml or, like Logstash's default,
+// the CPUs available to the container.
+func (l *Launcher) MemorySettings() memory.Settings {
+	s := l.Config.MemorySettings()
+	if s.Workers == 0 {
+		merged, err := settings.ReadFile(filepath.Join(l.Config.LogstashHome, "config", settings.FileName))
+		if err != nil {
+			l.Log.Warning("unable to read pipeline.workers: %s", err.Error())
+		}
+		if workers, ok := merged.Int("pipeline.workers"); ok {
+			s.Workers = workers
+		} else {
+			s.Workers = memory.AvailableCPUs(memory.CgroupDir)
+		}
+	}
+	return s
+}
+
+// RenderJvmOptions writes $LOGSTASH_HOME/config/jvm.options with the calculated memory settings
+// and the options of the `jvm:` section stored in $LS_ROOT/jvm.options during staging
+func (l *Launcher) RenderJvmOptions() error {
+	flags, result, err := l.MemoryFlags()
+	if err != nil {
+		return fmt.Errorf("invalid memory configuration: %s", err.Error())
+	}
+	if result != nil {
+		l.Log.Info("Memory: %s", result.String())
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(l.Config.Root, jvm.FileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			flags = append(flags, line)
+		}
+	}
+
+	if err := jvm.Render(l.Config.LogstashHome, flags); err != nil {
+		return fmt.Errorf("unable to render jvm.options: %s", err.Error())
+	}
+	l.Log.Info("Using jvm.options %s", strings.Join(flags, " "))
+	return nil
+}
+
+// PrepareDirectories creates the directories used by the template processing
+func (l *Launcher) PrepareDirectories() error {
+	for _, dir := range []string{"conf.d", "grok-patterns", "curator.d", "bin"} {
+		if err := os.MkdirAll(filepath.Join(l.Config.Home, dir), 0755); err != nil {
+			return err
+		}
+	}
+
+	//rendered on every start
+	for _, dir := range []string{"logstash.conf.d", "curator.conf.d", "ofelia"} {
+		if err := os.RemoveAll(filepath.Join(l.Config.Home, dir)); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(l.Config.Home, dir), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderTemplates runs the runtime pass of the template processing
+func (l *Launcher) RenderTemplates() error {
+	home := l.Config.Home
+	root := l.Config.Root
+
+	renderings := append(l.pipelineRenderings(filepath.Join(home, "logstash.conf.d")), [][]string{
+		{filepath.Join(root, "grok-patterns"), filepath.Join(home, "grok-patterns")},
+		{filepath.Join(home, "curator.d"), filepath.Join(home, "curator.conf.d")},
+		{"-n", filepath.Join(root, "curator.d"), filepath.Join(home, "curator.conf.d")},
+	}...)
+	for _, file := range []string{"logstash.yml", pipelinesFileName} {
+		if _, err := os.Stat(filepath.Join(home, file)); err == nil {
+			renderings = append(renderings, []string{filepath.Join(home, file), filepath.Join(l.Config.LogstashHome, "config", file)})
+		}
+	}
+	renderings = append(renderings,
+		[]string{filepath.Join(root, "ofelia", "scripts"), filepath.Join(home, "bin")},
+		[]string{filepath.Join(root, "ofelia", "config"), filepath.Join(home, "ofelia")})
+
+	for _, args := range renderings {
+		if err := l.gte(args...); err != nil {
+			return err
+		}
+	}
+
+	scripts, err := filepath.Glob(filepath.Join(home, "bin", "*.sh"))
+	if err != nil {
+		return err
+	}
+	for _, script := range scripts {
+		if err := os.Chmod(script, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pipelineRenderings returns the gte arguments rendering the pipeline templates of the app and
+// the buildpack into dest
+func (l *Launcher) pipelineRenderings(dest string) [][]string {
+	return [][]string{
+		{filepath.Join(l.Config.Home, "conf.d"), dest},
+		{filepath.Join(l.Config.Root, "conf.d"), dest},
+	}
+}
+
+// RenderSettings merges the buildpack settings stored in $LS_ROOT/logstash.yml during staging
+// (e.g. `queue:`) into the logstash.yml of the app, or the one shipped with Logstash
+func (l *Launcher) RenderSettings() error {
+	configDir := filepath.Join(l.Config.LogstashHome, "config")
+	target := filepath.Join(configDir, settings.FileName)
+
+	//the logstash.yml of the app is rendered to the target by RenderTemplates
+	base := target
+	if _, err := os.Stat(filepath.Join(l.Config.Home, "logstash.yml")); os.IsNotExist(err) {
+		base = filepath.Join(configDir, settings.OriginalFileName)
+	}
+
+	buildpackSettings, err := settings.ReadFile(filepath.Join(l.Config.Root, settings.FileName))
+	if err != nil {
+		return err
+	}
+	if len(buildpackSettings) == 0 {
+		if base == target {
+			return nil
+		}
+		original, err := ioutil.ReadFile(base)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, original, 0644)
+	}
+
+	baseSettings, err := settings.ReadFile(base)
+	if err != nil {
+		return err
+	}
+	buildpackSettings.ResolvePaths(l.Config.Home)
+
+	merged, overridden := settings.Merge(baseSettings, buildpackSettings)
+	for _, key := range overridden {
+		l.Log.Warning("logstash.yml: '%s' is overridden by the Logstash file", key)
+	}
+	l.Log.Info("Using logstash.yml settings %s", strings.Join(buildpackSettings.Keys(), ", "))
+	return settings.WriteFile(target, merged)
+}
+
+// CheckCertificates logs the installed certificates and warns about (nearly) expired ones
+func (l *Launcher) CheckCertificates(now time.Time) {
+	var files []string
+	for _, dir := range []string{"certificates", "keystores"} {
+		matches, _ := filepath.Glob(filepath.Join(l.Config.Root, dir, "*.pem"))
+		files = append(files, matches...)
+	}
+
+	for _, file := range files {
+		if filepath.Base(file) == "ca-bundle.pem" {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".pem")
+
+		certs, err := certificates.ParseFile(file)
+		if err != nil {
+			l.Log.Warning("certificate %s can't be read: %s", name, err.Error())
+			continue
+		}
+		for _, cert := range certs {
+			l.Log.Info("%s: %s", name, certificates.Describe(cert))
+			if certificates.Expired(cert, now) {
+				l.Log.Warning("certificate %s is expired", name)
+			} else if certificates.ExpiresWithin(cert, now, l.Config.CertExpiryWarningDays) {
+				l.Log.Warning("certificate %s expires within %d days", name, l.Config.CertExpiryWarningDays)
+			}
+		}
+	}
+}
+
+// LogstashArgs returns the command line of Logstash. The rendered logstash.conf.d is used unless
+// logstash.yml defines the pipelines (`path.config`, `config.string` or x-pack centralized
+// pipeline management).
+func (l *Launcher) LogstashArgs() ([]string, error) {
+	args := []string{}
+
+	merged, err := settings.ReadFile(filepath.Join(l.Config.LogstashHome, "config", settings.FileName))
+	if err != nil {
+		return nil, err
+	}
+	pipelines, err := readPipelines(filepath.Join(l.Config.LogstashHome, "config", pipelinesFileName))
+	if err != nil {
+		return nil, err
+	}
+	if !definesPipelines(merged) && len(pipelines) == 0 {
+		args = append(args, "-f", "logstash.conf.d")
+	}
+
+	return append(args, strings.Fields(l.Config.CmdArgs)...), nil
+}
+
+func definesPipelines(s settings.Settings) bool {
+	for _, key := range []string{"path.config", "config.string"} {
+		if value, ok := s[key]; ok && fmt.Sprintf("%v", value) != "" {
+			return true
+		}
+	}
+	return fmt.Sprintf("%v", s["xpack.management.enabled"]) == "true"
+}
+
+// readPipelines returns the pipelines of pipelines.yml, the file shipped with Logstash only
+// contains comments
+func readPipelines(file string) ([]map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	pipelines := []map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &pipelines); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", pipelinesFileName, err.Error())
+	}
+	return pipelines, nil
+}
+
+func (l *Launcher) gte(args ...string) error {
+	if err := l.command(filepath.Join(l.Config.GteHome, "gte"), args...).Run(); err != nil {
+		return fmt.Errorf("template processing 'gte %s' failed: %s", strings.Join(args, " "), err.Error())
+	}
+	return nil
+}
+
+func (l *Launcher) command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = l.Config.Home
+	cmd.Stdout = l.Stdout
+	cmd.Stderr = l.Stderr
+	return cmd
+}
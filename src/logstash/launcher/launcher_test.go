@@ -0,0 +1,849 @@
+package launcher_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"logstash/launcher"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Launcher", func() {
+	var (
+		env    map[string]string
+		getenv func(string) string
+	)
+
+	BeforeEach(func() {
+		env = map[string]string{
+			"HOME":          "/home/vcap/app",
+			"LS_ROOT":       "/home/vcap/deps/0",
+			"LOGSTASH_HOME": "/home/vcap/deps/0/logstash-6.1.3",
+			"GTE_HOME":      "/home/vcap/deps/0/gte-1.0.0",
+		}
+		getenv = func(name string) string { return env[name] }
+	})
+
+	Describe("ConfigFromEnv", func() {
+		It("reads the profile.d settings", func() {
+			env["LS_BP_RESERVED_MEMORY"] = "300"
+			env["LS_BP_HEAP_PERCENTAGE"] = "90"
+			env["LS_CMD_ARGS"] = "--log.level debug"
+			env["VCAP_APPLICATION"] = `{"application_name":"logstash","limits":{"mem":2048,"disk":1024}}`
+
+			config, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.ReservedMemory).To(Equal(300))
+			Expect(config.HeapPercentage).To(Equal(90))
+			Expect(config.MemoryLimit).To(Equal(2048))
+			Expect(config.CmdArgs).To(Equal("--log.level debug"))
+			Expect(config.CertExpiryWarningDays).To(Equal(30))
+			Expect(config.CuratorEnabled).To(BeFalse())
+		})
+
+		It("fails if a dependency location is missing", func() {
+			delete(env, "GTE_HOME")
+			_, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(MatchError("GTE_HOME is not set"))
+		})
+
+		It("fails on invalid numbers", func() {
+			env["LS_BP_HEAP_PERCENTAGE"] = "ninety"
+			_, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("LS_BP_HEAP_PERCENTAGE"))
+		})
+
+		It("schedules Curator according to the curator mode", func() {
+			env["LS_CURATOR_ENABLED"] = "enabled"
+			env["OFELIA_HOME"] = "/home/vcap/deps/0/ofelia"
+			config, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.CuratorMode).To(Equal(launcher.CuratorModeDaemon))
+			Expect(config.SchedulesCurator()).To(BeTrue())
+
+			env["LS_CURATOR_MODE"] = "leader"
+			env["CF_INSTANCE_INDEX"] = "1"
+			config, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.SchedulesCurator()).To(BeFalse())
+
+			env["CF_INSTANCE_INDEX"] = "0"
+			config, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.SchedulesCurator()).To(BeTrue())
+
+			env["LS_CURATOR_MODE"] = "task"
+			config, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.SchedulesCurator()).To(BeFalse())
+
+			env["LS_CURATOR_MODE"] = "cron"
+			_, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).To(MatchError("invalid LS_CURATOR_MODE 'cron'"))
+		})
+
+		It("validates the banner format", func() {
+			config, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.BannerFormat).To(Equal(launcher.BannerFormatText))
+
+			env["LS_BANNER_FORMAT"] = "JSON"
+			config, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.BannerFormat).To(Equal(launcher.BannerFormatJSON))
+
+			env["LS_BANNER_FORMAT"] = "xml"
+			_, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("reads the drain timeout", func() {
+			config, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.DrainTimeout).To(Equal(launcher.DefaultDrainTimeout))
+
+			env["LS_BP_DRAIN_TIMEOUT"] = "25"
+			config, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.DrainTimeout).To(Equal(25 * time.Second))
+		})
+
+		It("reads the health check settings", func() {
+			env["PORT"] = "8080"
+			env["LS_BP_HEALTH_CHECK"] = "enabled"
+			env["LS_BP_HEALTH_CHECK_PATH"] = "/_health"
+			env["LS_BP_HEALTH_CHECK_INTERNAL_PORT"] = "8081"
+			env["LS_BP_HEALTH_CHECK_API_PORT"] = "9600"
+			env["LS_BP_HEALTH_CHECK_STALL_TIMEOUT"] = "60"
+
+			config, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.Port).To(Equal(8080))
+			Expect(config.HealthCheck).To(Equal(launcher.HealthCheckConfig{
+				Enabled: true, Path: "/_health", InternalPort: 8081, APIPort: 9600, StallTimeout: time.Minute,
+			}))
+
+			delete(env, "PORT")
+			_, err = launcher.ConfigFromEnv(getenv)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("enables the maintenance mode", func() {
+			for value, enabled := range map[string]bool{"true": true, "1": true, "false": false, "0": false, "": false} {
+				env["LS_MAINTENANCE_MODE"] = value
+				config, err := launcher.ConfigFromEnv(getenv)
+				Expect(err).To(BeNil())
+				Expect(config.Maintenance).To(Equal(enabled))
+			}
+
+			env["LS_DO_SLEEP"] = "yes"
+			config, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.Maintenance).To(BeTrue())
+		})
+
+		It("reads the reload settings", func() {
+			env["LS_BP_RELOAD"] = "enabled"
+			env["LS_BP_RELOAD_POLL_INTERVAL"] = "30"
+			config, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).To(BeNil())
+			Expect(config.Reload).To(BeTrue())
+			Expect(config.ReloadPollInterval).To(Equal(30 * time.Second))
+		})
+
+		It("fails on an invalid VCAP_APPLICATION", func() {
+			env["VCAP_APPLICATION"] = `{"limits":`
+			_, err := launcher.ConfigFromEnv(getenv)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("MemoryFlags", func() {
+		It("calculates the memory settings from the memory limit", func() {
+			l := &launcher.Launcher{Config: launcher.Config{MemoryLimit: 2048, HeapPercentage: 90, Workers: 4}}
+			flags, result, err := l.MemoryFlags()
+			Expect(err).To(BeNil())
+			Expect(result).NotTo(BeNil())
+			Expect(flags[:3]).To(Equal([]string{"-Xmx1563m", "-Xms1563m", "-XX:MaxMetaspaceSize=125m"}))
+		})
+
+		It("refuses a memory limit which is too small", func() {
+			l := &launcher.Launcher{Config: launcher.Config{MemoryLimit: 400, HeapPercentage: 90, Workers: 1}}
+			_, _, err := l.MemoryFlags()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("with an app directory", func() {
+		var (
+			home   string
+			root   string
+			l      *launcher.Launcher
+			buffer *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			var err error
+			home, err = ioutil.TempDir("", "launcher-home")
+			Expect(err).To(BeNil())
+			root, err = ioutil.TempDir("", "launcher-root")
+			Expect(err).To(BeNil())
+
+			//fake gte which records its arguments
+			Expect(os.MkdirAll(filepath.Join(root, "gte"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(root, "gte", "gte"), []byte("#!/bin/sh\nprintf '%s\\n' \"$*\" >> "+filepath.Join(root, "gte.log")+"\n"), 0755)).To(Succeed())
+
+			buffer = new(bytes.Buffer)
+			l = &launcher.Launcher{
+				Config: launcher.Config{Home: home, Root: root, GteHome: filepath.Join(root, "gte"), LogstashHome: filepath.Join(root, "logstash")},
+				Log:    libbuildpack.NewLogger(buffer),
+				Stdout: buffer,
+				Stderr: buffer,
+				Redact: func(text string) string { return text },
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(home)
+			os.RemoveAll(root)
+		})
+
+		Describe("RenderTemplates", func() {
+			It("renders all template directories", func() {
+				Expect(l.PrepareDirectories()).To(Succeed())
+				Expect(l.RenderTemplates()).To(Succeed())
+
+				log, err := ioutil.ReadFile(filepath.Join(root, "gte.log"))
+				Expect(err).To(BeNil())
+				lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+				Expect(lines).To(HaveLen(7))
+				Expect(lines[0]).To(Equal(filepath.Join(home, "conf.d") + " " + filepath.Join(home, "logstash.conf.d")))
+				Expect(lines[4]).To(HavePrefix("-n "))
+			})
+
+			It("renders logstash.yml if it exists", func() {
+				Expect(ioutil.WriteFile(filepath.Join(home, "logstash.yml"), []byte("pipeline.workers: 2\n"), 0644)).To(Succeed())
+				Expect(l.RenderTemplates()).To(Succeed())
+
+				log, err := ioutil.ReadFile(filepath.Join(root, "gte.log"))
+				Expect(err).To(BeNil())
+				Expect(string(log)).To(ContainSubstring(filepath.Join(root, "logstash", "config", "logstash.yml")))
+			})
+
+			It("fails with the failing command", func() {
+				Expect(ioutil.WriteFile(filepath.Join(root, "gte", "gte"), []byte("#!/bin/sh\nexit 1\n"), 0755)).To(Succeed())
+				err := l.RenderTemplates()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("template processing 'gte " + filepath.Join(home, "conf.d")))
+			})
+		})
+
+		Describe("RenderSettings", func() {
+			BeforeEach(func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "logstash.yml.orig"), []byte("# all settings commented out\n"), 0644)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash.yml"), []byte("queue.type: persisted\npath.queue: queue\n"), 0644)).To(Succeed())
+			})
+
+			It("merges the buildpack settings into the logstash.yml of the app", func() {
+				Expect(ioutil.WriteFile(filepath.Join(home, "logstash.yml"), []byte("queue:\n  type: memory\npipeline.workers: 2\n"), 0644)).To(Succeed())
+				//rendered by RenderTemplates
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "logstash.yml"), []byte("queue:\n  type: memory\npipeline.workers: 2\n"), 0644)).To(Succeed())
+
+				Expect(l.RenderSettings()).To(Succeed())
+
+				data, err := ioutil.ReadFile(filepath.Join(root, "logstash", "config", "logstash.yml"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(Equal("path.queue: " + filepath.Join(home, "queue") + "\npipeline.workers: 2\nqueue.type: persisted\n"))
+				Expect(buffer.String()).To(ContainSubstring("'queue.type' is overridden by the Logstash file"))
+			})
+
+			It("uses the original logstash.yml without logstash.yml in the app", func() {
+				Expect(l.RenderSettings()).To(Succeed())
+
+				data, err := ioutil.ReadFile(filepath.Join(root, "logstash", "config", "logstash.yml"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(Equal("path.queue: " + filepath.Join(home, "queue") + "\nqueue.type: persisted\n"))
+			})
+
+			It("restores the original logstash.yml without buildpack settings", func() {
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash.yml"), []byte{}, 0644)).To(Succeed())
+				Expect(l.RenderSettings()).To(Succeed())
+
+				data, err := ioutil.ReadFile(filepath.Join(root, "logstash", "config", "logstash.yml"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(Equal("# all settings commented out\n"))
+			})
+		})
+
+		Describe("Reloader", func() {
+			var reloader *launcher.Reloader
+
+			BeforeEach(func() {
+				//fake gte which copies the templates
+				Expect(ioutil.WriteFile(filepath.Join(root, "gte", "gte"), []byte("#!/bin/sh\ncp \"$1\"/* \"$2\"/ 2>/dev/null\nexit 0\n"), 0755)).To(Succeed())
+				Expect(l.PrepareDirectories()).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(home, "conf.d", "input.conf"), []byte("input { stdin {} }\n"), 0644)).To(Succeed())
+				reloader = launcher.NewReloader(l)
+			})
+
+			It("updates logstash.conf.d only if the rendered templates changed", func() {
+				changed, err := reloader.Reload()
+				Expect(err).To(BeNil())
+				Expect(changed).To(BeTrue())
+
+				changed, err = reloader.Reload()
+				Expect(err).To(BeNil())
+				Expect(changed).To(BeFalse())
+
+				Expect(ioutil.WriteFile(filepath.Join(home, "conf.d", "input.conf"), []byte("input { http {} }\n"), 0644)).To(Succeed())
+				changed, err = reloader.Reload()
+				Expect(err).To(BeNil())
+				Expect(changed).To(BeTrue())
+				data, err := ioutil.ReadFile(filepath.Join(home, "logstash.conf.d", "input.conf"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(Equal("input { http {} }\n"))
+			})
+
+			It("removes files of deleted templates", func() {
+				Expect(ioutil.WriteFile(filepath.Join(home, "logstash.conf.d", "old.conf"), []byte("filter {}\n"), 0644)).To(Succeed())
+				changed, err := reloader.Reload()
+				Expect(err).To(BeNil())
+				Expect(changed).To(BeTrue())
+
+				files, err := ioutil.ReadDir(filepath.Join(home, "logstash.conf.d"))
+				Expect(err).To(BeNil())
+				Expect(files).To(HaveLen(1))
+				Expect(files[0].Name()).To(Equal("input.conf"))
+			})
+
+			It("keeps logstash.conf.d if the rendering fails", func() {
+				Expect(ioutil.WriteFile(filepath.Join(home, "logstash.conf.d", "input.conf"), []byte("input { stdin {} }\n"), 0644)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "gte", "gte"), []byte("#!/bin/sh\nexit 1\n"), 0755)).To(Succeed())
+				_, err := reloader.Reload()
+				Expect(err).NotTo(BeNil())
+				Expect(filepath.Join(home, "logstash.conf.d", "input.conf")).To(BeARegularFile())
+			})
+		})
+
+		Describe("WriteDiagnostics", func() {
+			var dir string
+
+			BeforeEach(func() {
+				dir = filepath.Join(home, launcher.DiagnosticsDir)
+				Expect(l.PrepareDirectories()).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(home, "logstash.conf.d", "output.conf"), []byte("output { http { password => \"secret\" } }\n"), 0644)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "bin"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "bin", "logstash"), []byte("#!/bin/sh\necho \"logstash $*\"\nexit 1\n"), 0755)).To(Succeed())
+				l.Redact = func(text string) string { return strings.Replace(text, "secret", "[REDACTED]", -1) }
+			})
+
+			It("writes the rendered configuration, the environment and the config test", func() {
+				Expect(l.WriteDiagnostics(dir, nil)).To(Succeed())
+
+				data, err := ioutil.ReadFile(filepath.Join(dir, "logstash.conf.d", "output.conf"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(ContainSubstring("[REDACTED]"))
+				Expect(string(data)).NotTo(ContainSubstring("secret"))
+
+				Expect(filepath.Join(dir, "environment.txt")).To(BeARegularFile())
+
+				data, err = ioutil.ReadFile(filepath.Join(dir, "config-test.log"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(ContainSubstring("logstash -f logstash.conf.d -t"))
+				Expect(string(data)).To(ContainSubstring("configuration test failed"))
+			})
+
+			It("redacts the copied settings and tests the configuration without reload", func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "pipelines.yml"), []byte("- pipeline.id: main\n  path.config: /home/vcap/app/secret.conf\n"), 0644)).To(Succeed())
+				l.Config.Reload = true
+
+				Expect(l.WriteDiagnostics(dir, nil)).To(Succeed())
+
+				data, err := ioutil.ReadFile(filepath.Join(dir, "pipelines.yml"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(ContainSubstring("[REDACTED].conf"))
+
+				data, err = ioutil.ReadFile(filepath.Join(dir, "config-test.log"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(ContainSubstring("-t"))
+				Expect(string(data)).NotTo(ContainSubstring("config.reload.automatic"))
+			})
+
+			It("records a failed preparation instead of testing the configuration", func() {
+				Expect(l.WriteDiagnostics(dir, errors.New("template processing failed"))).To(Succeed())
+
+				data, err := ioutil.ReadFile(filepath.Join(dir, "prepare-error.txt"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(Equal("template processing failed\n"))
+				Expect(filepath.Join(dir, "config-test.log")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Describe("RunMaintenance", func() {
+			It("answers the health check until the app is stopped", func() {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).To(BeNil())
+				l.Config.Port = listener.Addr().(*net.TCPAddr).Port
+				listener.Close()
+				//fails, the diagnostics contain the error
+				Expect(ioutil.WriteFile(filepath.Join(root, "gte", "gte"), []byte("#!/bin/sh\nexit 1\n"), 0755)).To(Succeed())
+
+				signals := make(chan os.Signal, 1)
+				done := make(chan int)
+				go func() {
+					defer GinkgoRecover()
+					code, err := launcher.RunMaintenance(l, signals)
+					Expect(err).To(BeNil())
+					done <- code
+				}()
+
+				Eventually(func() error {
+					resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/_health", l.Config.Port))
+					if err == nil {
+						resp.Body.Close()
+						if resp.StatusCode != 200 {
+							err = fmt.Errorf("status %d", resp.StatusCode)
+						}
+					}
+					return err
+				}, 5*time.Second).Should(Succeed())
+				Eventually(filepath.Join(home, launcher.DiagnosticsDir, "prepare-error.txt"), 5*time.Second).Should(BeARegularFile())
+
+				signals <- syscall.SIGTERM
+				Eventually(done, 5*time.Second).Should(Receive(Equal(0)))
+			})
+		})
+
+		Describe("PrintBanner", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(root, "config.yml"), []byte(`name: logstash
+config:
+  LogstashVersion: 6.1.3
+  OpenJdkVersion: 1.8.0
+  CuratorInstalled: false
+  Templates:
+  - Name: cf-output-elasticsearch
+    ServiceInstanceName: my-elasticsearch
+  Services:
+  - my-elasticsearch
+  Plugins:
+  - logstash-filter-translate (3.0.4)
+  Memory: heap 900M of 1024M
+`), 0644)).To(Succeed())
+				Expect(l.PrepareDirectories()).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(home, "logstash.conf.d", "output.conf"), []byte("output {}\n"), 0644)).To(Succeed())
+				l.Config.Reload = true
+			})
+
+			It("combines the staging results with the rendered configuration", func() {
+				banner := l.Banner()
+				Expect(banner.Logstash).To(Equal("6.1.3"))
+				Expect(banner.Templates).To(Equal([]string{"cf-output-elasticsearch (my-elasticsearch)"}))
+				Expect(banner.Plugins).To(Equal([]string{"logstash-filter-translate (3.0.4)"}))
+				Expect(banner.Memory).To(Equal("heap 900M of 1024M"))
+				Expect(banner.PipelineFiles).To(Equal([]string{"output.conf"}))
+				Expect(banner.GrokPatterns).To(BeEmpty())
+				Expect(banner.Features).To(Equal([]string{"reload"}))
+			})
+
+			It("prints the banner as text", func() {
+				l.Config.BannerFormat = launcher.BannerFormatText
+				l.PrintBanner()
+				Expect(buffer.String()).To(ContainSubstring("Logstash:       6.1.3 (OpenJDK 1.8.0)"))
+				Expect(buffer.String()).To(ContainSubstring("Grok patterns:  none"))
+			})
+
+			It("prints the banner as a single line of json", func() {
+				l.Config.BannerFormat = launcher.BannerFormatJSON
+				l.PrintBanner()
+				Expect(buffer.String()).To(HavePrefix(`{"startup_banner":{"logstash":"6.1.3","openjdk":"1.8.0",`))
+				Expect(strings.Count(buffer.String(), "\n")).To(Equal(1))
+			})
+		})
+
+		Describe("RunProcess", func() {
+			BeforeEach(func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "bin"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "bin", "logstash"), []byte("#!/bin/sh\necho \"logstash $*\"\nexit 3\n"), 0755)).To(Succeed())
+			})
+
+			It("returns the exit code of the Logstash config test for config-check", func() {
+				code, err := launcher.RunProcess(l, launcher.ProcessConfigCheck)
+				Expect(err).To(BeNil())
+				Expect(code).To(Equal(3))
+				Expect(buffer.String()).To(ContainSubstring("logstash -f logstash.conf.d -t"))
+				Expect(buffer.String()).To(ContainSubstring("the Logstash configuration is invalid"))
+			})
+
+			It("fails config-check if the preparation fails", func() {
+				Expect(ioutil.WriteFile(filepath.Join(root, "gte", "gte"), []byte("#!/bin/sh\nexit 1\n"), 0755)).To(Succeed())
+				code, err := launcher.RunProcess(l, launcher.ProcessConfigCheck)
+				Expect(err).NotTo(BeNil())
+				Expect(code).To(Equal(1))
+				Expect(buffer.String()).NotTo(ContainSubstring("logstash -f"))
+			})
+
+			It("refuses the curator process without Curator", func() {
+				code, err := launcher.RunProcess(l, launcher.ProcessCurator)
+				Expect(err).To(MatchError(ContainSubstring("Curator is not installed")))
+				Expect(code).To(Equal(1))
+			})
+
+			It("returns the exit code of Curator for curator-task", func() {
+				l.Config.CuratorEnabled = true
+				//the fake gte doesn't render, the script is created like the rendered one
+				Expect(os.MkdirAll(filepath.Join(home, "bin"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(home, "bin", "curator.sh"), []byte("#!/bin/sh\necho curator\nexit 4\n"), 0755)).To(Succeed())
+
+				code, err := launcher.RunProcess(l, launcher.ProcessCuratorTask)
+				Expect(err).To(BeNil())
+				Expect(code).To(Equal(4))
+				Expect(buffer.String()).To(ContainSubstring("curator\n"))
+			})
+
+			It("rejects unknown process types", func() {
+				code, err := launcher.RunProcess(l, "unknown")
+				Expect(err).To(MatchError(ContainSubstring("unknown process type 'unknown'")))
+				Expect(code).To(Equal(2))
+			})
+		})
+
+		Describe("MemorySettings", func() {
+			It("uses the pipeline.workers of logstash.yml without memory.workers", func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "logstash.yml"), []byte("pipeline:\n  workers: 3\n"), 0644)).To(Succeed())
+				Expect(l.MemorySettings().Workers).To(Equal(3))
+
+				l.Config.Workers = 5
+				Expect(l.MemorySettings().Workers).To(Equal(5))
+			})
+		})
+
+		Describe("RenderJvmOptions", func() {
+			It("merges the memory settings and the jvm options into jvm.options", func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "jvm.options"), []byte("-Xms1g\n-Xmx1g\n-XX:+UseConcMarkSweepGC\n-Dfile.encoding=UTF-8\n"), 0644)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "jvm.options"), []byte("-XX:+UseG1GC\n-Dfoo=bar\n"), 0644)).To(Succeed())
+				l.Config.MemoryLimit = 2048
+				l.Config.HeapPercentage = 90
+				l.Config.Workers = 4
+
+				Expect(l.RenderJvmOptions()).To(Succeed())
+
+				data, err := ioutil.ReadFile(filepath.Join(root, "logstash", "config", "jvm.options"))
+				Expect(err).To(BeNil())
+				Expect(string(data)).To(ContainSubstring("# replaced by the buildpack: -Xmx1g\n"))
+				Expect(string(data)).To(ContainSubstring("# replaced by the buildpack: -XX:+UseConcMarkSweepGC\n"))
+				Expect(string(data)).To(ContainSubstring("\n-Dfile.encoding=UTF-8\n"))
+				Expect(string(data)).To(HaveSuffix("-Xmx1563m\n-Xms1563m\n-XX:MaxMetaspaceSize=125m\n-XX:MaxDirectMemorySize=64m\n-XX:ReservedCodeCacheSize=64m\n-Xss1024k\n-XX:+UseG1GC\n-Dfoo=bar\n"))
+
+				//the original is kept for the next start
+				Expect(filepath.Join(root, "logstash", "config", "jvm.options.orig")).To(BeAnExistingFile())
+				Expect(l.RenderJvmOptions()).To(Succeed())
+				again, err := ioutil.ReadFile(filepath.Join(root, "logstash", "config", "jvm.options"))
+				Expect(err).To(BeNil())
+				Expect(string(again)).To(Equal(string(data)))
+			})
+		})
+
+		Describe("LogstashArgs", func() {
+			It("uses logstash.conf.d and the command line arguments", func() {
+				l.Config.CmdArgs = " --log.level  debug "
+				args, err := l.LogstashArgs()
+				Expect(err).To(BeNil())
+				Expect(args).To(Equal([]string{"-f", "logstash.conf.d", "--log.level", "debug"}))
+			})
+
+			It("omits logstash.conf.d with centralized pipeline management", func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "logstash.yml"), []byte("xpack.management:\n  enabled: true\n  pipeline.id: [main]\n"), 0644)).To(Succeed())
+				args, err := l.LogstashArgs()
+				Expect(err).To(BeNil())
+				Expect(args).To(BeEmpty())
+			})
+
+			It("omits logstash.conf.d with pipelines in pipelines.yml", func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "pipelines.yml"), []byte("- pipeline.id: main\n  path.config: /home/vcap/app/pipelines/main\n"), 0644)).To(Succeed())
+				args, err := l.LogstashArgs()
+				Expect(err).To(BeNil())
+				Expect(args).To(BeEmpty())
+			})
+
+			It("keeps logstash.conf.d with the commented pipelines.yml of Logstash", func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "pipelines.yml"), []byte("# - pipeline.id: test\n#   pipeline.workers: 1\n"), 0644)).To(Succeed())
+				args, err := l.LogstashArgs()
+				Expect(err).To(BeNil())
+				Expect(args).To(Equal([]string{"-f", "logstash.conf.d"}))
+			})
+
+			It("keeps logstash.conf.d with other pipeline settings", func() {
+				Expect(os.MkdirAll(filepath.Join(root, "logstash", "config"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "logstash", "config", "logstash.yml"), []byte("pipeline.workers: 2\nxpack.management.enabled: false\n"), 0644)).To(Succeed())
+				args, err := l.LogstashArgs()
+				Expect(err).To(BeNil())
+				Expect(args).To(Equal([]string{"-f", "logstash.conf.d"}))
+			})
+		})
+	})
+
+	Describe("Supervisor", func() {
+		var (
+			supervisor *launcher.Supervisor
+			signals    chan os.Signal
+		)
+
+		BeforeEach(func() {
+			signals = make(chan os.Signal, 1)
+			supervisor = launcher.NewSupervisor(libbuildpack.NewLogger(new(bytes.Buffer)), signals)
+		})
+
+		It("returns the exit code of the main process", func() {
+			Expect(supervisor.StartMain("main", exec.Command("/bin/sh", "-c", "exit 3"))).To(Succeed())
+			code, err := supervisor.Wait()
+			Expect(err).To(BeNil())
+			Expect(code).To(Equal(3))
+		})
+
+		It("forwards signals", func() {
+			Expect(supervisor.StartMain("main", exec.Command("/bin/sh", "-c", "trap 'exit 7' TERM; while true; do sleep 0.1; done"))).To(Succeed())
+			time.Sleep(200 * time.Millisecond)
+			signals <- syscall.SIGTERM
+			code, err := supervisor.Wait()
+			Expect(err).To(BeNil())
+			Expect(code).To(Equal(7))
+		})
+
+		It("stops the main process if a helper dies", func() {
+			Expect(supervisor.StartHelper("helper", exec.Command("/bin/sh", "-c", "sleep 0.2; exit 2"))).To(Succeed())
+			Expect(supervisor.StartMain("main", exec.Command("/bin/sh", "-c", "trap 'exit 0' TERM; while true; do sleep 0.1; done"))).To(Succeed())
+			code, err := supervisor.Wait()
+			Expect(err).To(MatchError("helper exited unexpectedly with code 2"))
+			Expect(code).To(Equal(1))
+		})
+
+		It("calls OnHangup before forwarding SIGHUP", func() {
+			hangups := make(chan struct{}, 1)
+			supervisor.OnHangup = func() { hangups <- struct{}{} }
+			Expect(supervisor.StartMain("main", exec.Command("/bin/sh", "-c", "trap 'exit 5' HUP; while true; do sleep 0.1; done"))).To(Succeed())
+			time.Sleep(200 * time.Millisecond)
+			signals <- syscall.SIGHUP
+			code, err := supervisor.Wait()
+			Expect(err).To(BeNil())
+			Expect(code).To(Equal(5))
+			Expect(hangups).To(Receive())
+		})
+
+		It("doesn't forward SIGHUP to the helpers", func() {
+			Expect(supervisor.StartHelper("helper", exec.Command("/bin/sh", "-c", "trap 'exit 0' TERM; while true; do sleep 0.1; done"))).To(Succeed())
+			Expect(supervisor.StartMain("main", exec.Command("/bin/sh", "-c", "trap 'sleep 0.3; exit 5' HUP; while true; do sleep 0.1; done"))).To(Succeed())
+			time.Sleep(200 * time.Millisecond)
+			signals <- syscall.SIGHUP
+			code, err := supervisor.Wait()
+			Expect(err).To(BeNil())
+			Expect(code).To(Equal(5))
+		})
+
+		It("kills the main process after the drain timeout", func() {
+			supervisor.DrainTimeout = 300 * time.Millisecond
+			Expect(supervisor.StartMain("main", exec.Command("/bin/sh", "-c", "trap '' TERM; while true; do sleep 0.1; done"))).To(Succeed())
+			time.Sleep(200 * time.Millisecond)
+			start := time.Now()
+			signals <- syscall.SIGTERM
+			code, err := supervisor.Wait()
+			Expect(err).To(BeNil())
+			Expect(code).To(Equal(137))
+			Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+		})
+
+		It("stops the helpers with the main process on SIGTERM", func() {
+			Expect(supervisor.StartHelper("helper", exec.Command("/bin/sh", "-c", "trap 'exit 0' TERM; while true; do sleep 0.1; done"))).To(Succeed())
+			Expect(supervisor.StartMain("main", exec.Command("/bin/sh", "-c", "trap 'sleep 0.3; exit 0' TERM; while true; do sleep 0.1; done"))).To(Succeed())
+			time.Sleep(200 * time.Millisecond)
+			signals <- syscall.SIGTERM
+			code, err := supervisor.Wait()
+			Expect(err).To(BeNil())
+			Expect(code).To(Equal(0))
+		})
+
+		It("fails if the process can't be started", func() {
+			err := supervisor.StartMain("main", exec.Command("/does/not/exist"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("HealthChecker", func() {
+		var (
+			checker *launcher.HealthChecker
+			stats   string
+			server  *httptest.Server
+			now     time.Time
+		)
+
+		BeforeEach(func() {
+			stats = `{"pipelines":{"main":{"events":{"in":10,"out":10},"queue":{"type":"memory"}}}}`
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/_node/stats/pipelines"))
+				w.Write([]byte(stats))
+			}))
+			checker = launcher.NewHealthChecker(9600, time.Minute)
+			checker.StatsURL = server.URL + "/_node/stats/pipelines"
+			now = time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("is healthy with running pipelines", func() {
+			health := checker.Check(now)
+			Expect(health.Healthy).To(BeTrue())
+			Expect(health.Pipelines).To(Equal(map[string]string{"main": "running"}))
+			Expect(checker.Health()).To(Equal(health))
+		})
+
+		It("is unhealthy without pipelines", func() {
+			stats = `{"pipelines":{}}`
+			health := checker.Check(now)
+			Expect(health.Healthy).To(BeFalse())
+			Expect(health.Reasons).To(Equal([]string{"no pipeline running"}))
+		})
+
+		It("is unhealthy if the node stats are unavailable", func() {
+			server.Close()
+			health := checker.Check(now)
+			Expect(health.Healthy).To(BeFalse())
+			Expect(health.Reasons[0]).To(HavePrefix("node stats unavailable"))
+		})
+
+		It("detects pipelines without progress", func() {
+			stats = `{"pipelines":{"main":{"events":{"in":20,"filtered":10,"out":10}}}}`
+			Expect(checker.Check(now).Healthy).To(BeTrue())
+			Expect(checker.Check(now.Add(30 * time.Second)).Healthy).To(BeTrue())
+
+			stats = `{"pipelines":{"main":{"events":{"in":40,"filtered":10,"out":10}}}}`
+			health := checker.Check(now.Add(75 * time.Second))
+			Expect(health.Healthy).To(BeFalse())
+			Expect(health.Pipelines["main"]).To(Equal("stalled"))
+			Expect(health.Reasons).To(Equal([]string{"pipeline main: 30 events waiting, no progress for 1m15s"}))
+
+			stats = `{"pipelines":{"main":{"events":{"in":40,"filtered":11,"out":10}}}}`
+			Expect(checker.Check(now.Add(80 * time.Second)).Healthy).To(BeTrue())
+		})
+
+		It("ignores events removed by filters", func() {
+			stats = `{"pipelines":{"main":{"events":{"in":100,"filtered":100,"out":40}}}}`
+			Expect(checker.Check(now).Healthy).To(BeTrue())
+			health := checker.Check(now.Add(5 * time.Minute))
+			Expect(health.Healthy).To(BeTrue())
+			Expect(health.Pipelines["main"]).To(Equal("running"))
+		})
+
+		It("detects events stuck in the persisted queue", func() {
+			stats = `{"pipelines":{"main":{"events":{"in":100,"filtered":100,"out":100},"queue":{"type":"persisted","events":5}}}}`
+			Expect(checker.Check(now).Healthy).To(BeTrue())
+			health := checker.Check(now.Add(2 * time.Minute))
+			Expect(health.Healthy).To(BeFalse())
+			Expect(health.Reasons).To(Equal([]string{"pipeline main: 5 events waiting, no progress for 2m0s"}))
+		})
+
+		It("is unhealthy if a persisted queue is full", func() {
+			stats = `{"pipelines":{"main":{"events":{"in":10,"out":10},"queue":{"type":"persisted","capacity":{"queue_size_in_bytes":1024,"max_queue_size_in_bytes":1024}}}}}`
+			health := checker.Check(now)
+			Expect(health.Healthy).To(BeFalse())
+			Expect(health.Pipelines["main"]).To(Equal("queue full"))
+		})
+	})
+
+	Describe("HealthProxy", func() {
+		var (
+			proxy  *launcher.HealthProxy
+			input  net.Listener
+			health launcher.Health
+		)
+
+		BeforeEach(func() {
+			var err error
+			input, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			go func() {
+				for {
+					conn, err := input.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						defer conn.Close()
+						line, _ := bufio.NewReader(conn).ReadString('\n')
+						conn.Write([]byte("input: " + line))
+					}()
+				}
+			}()
+
+			health = launcher.Health{Healthy: true}
+			proxy = launcher.NewHealthProxy("/_health", input.Addr().(*net.TCPAddr).Port, func() launcher.Health { return health }, libbuildpack.NewLogger(new(bytes.Buffer)))
+			Expect(proxy.Listen("127.0.0.1:0")).To(Succeed())
+			go proxy.Serve()
+		})
+
+		AfterEach(func() {
+			proxy.Close()
+			input.Close()
+		})
+
+		It("answers the health check", func() {
+			resp, err := http.Get("http://" + proxy.Addr().String() + "/_health")
+			Expect(err).To(BeNil())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(200))
+			body, _ := ioutil.ReadAll(resp.Body)
+			Expect(string(body)).To(ContainSubstring(`"healthy":true`))
+		})
+
+		It("returns 503 if Logstash is unhealthy", func() {
+			health = launcher.Health{Reasons: []string{"no pipeline running"}}
+			resp, err := http.Get("http://" + proxy.Addr().String() + "/_health?verbose")
+			Expect(err).To(BeNil())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(503))
+		})
+
+		It("forwards other connections to the input", func() {
+			for _, line := range []string{"<34>1 2018-03-01T12:00:00Z host app - - - message\n", "GET /_healthz HTTP/1.1\r\n", "POST /_health HTTP/1.1\r\n"} {
+				conn, err := net.Dial("tcp", proxy.Addr().String())
+				Expect(err).To(BeNil())
+				conn.Write([]byte(line))
+				response, err := bufio.NewReader(conn).ReadString('\n')
+				conn.Close()
+				Expect(err).To(BeNil())
+				Expect(response).To(Equal("input: " + line))
+			}
+		})
+	})
+
+	Describe("ExitCode", func() {
+		It("returns 128+n for processes killed by a signal", func() {
+			err := exec.Command("/bin/sh", "-c", "kill -9 $$").Run()
+			Expect(launcher.ExitCode(err)).To(Equal(137))
+		})
+	})
+})
@@ -0,0 +1,142 @@
+package launcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"logstash/jvm"
+	"logstash/logging"
+	"logstash/settings"
+)
+
+// DiagnosticsDir is the directory in the app directory with the results of the maintenance mode
+const DiagnosticsDir = "diagnostics"
+
+// RunMaintenance prepares the runtime environment like a normal start but doesn't start Logstash.
+// The instance answers every http request on $PORT with 200 (health check) and writes the rendered
+// configuration, the redacted environment and the result of `logstash -t` to the diagnostics
+// directory. It returns once the app is stopped.
+func RunMaintenance(l *Launcher, signals <-chan os.Signal) (int, error) {
+	l.Log.Warning("MAINTENANCE MODE: Logstash is not started, unset LS_MAINTENANCE_MODE and restart the app to leave it")
+
+	if l.Config.Port > 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", l.Config.Port))
+		if err != nil {
+			return 1, fmt.Errorf("unable to listen on port %d: %s", l.Config.Port, err.Error())
+		}
+		defer listener.Close()
+		go http.Serve(listener, http.HandlerFunc(maintenanceHealth))
+	}
+
+	//render with the same PORT as a normal start
+	if l.Config.HealthCheck.Enabled {
+		if err := os.Setenv("PORT", fmt.Sprintf("%d", l.Config.HealthCheck.InternalPort)); err != nil {
+			return 1, err
+		}
+	}
+
+	prepareErr := l.Prepare()
+	if prepareErr != nil {
+		l.Log.Error("preparing the runtime environment failed: %s", prepareErr.Error())
+	}
+
+	dir := filepath.Join(l.Config.Home, DiagnosticsDir)
+	if err := l.WriteDiagnostics(dir, prepareErr); err != nil {
+		l.Log.Error("unable to write the diagnostics: %s", err.Error())
+	} else {
+		l.Log.Info("diagnostics written to %s, use `cf ssh` to inspect them", dir)
+	}
+
+	for sig := range signals {
+		if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+			l.Log.Info("received %s, leaving the maintenance mode", sig)
+			return 0, nil
+		}
+	}
+	return 0, nil
+}
+
+func maintenanceHealth(w http.ResponseWriter, r *http.Request) {
+	body, _ := json.Marshal(Health{Healthy: true, Maintenance: true, CheckedAt: time.Now().UTC()})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// WriteDiagnostics writes the rendered configuration and the environment, both redacted, and the
+// result of the Logstash config test to dir
+func (l *Launcher) WriteDiagnostics(dir string, prepareErr error) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "logstash.conf.d"), 0755); err != nil {
+		return err
+	}
+
+	if prepareErr != nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, "prepare-error.txt"), []byte(l.Redact(prepareErr.Error())+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	pipelineFiles, err := filepath.Glob(filepath.Join(l.Config.Home, "logstash.conf.d", "*"))
+	if err != nil {
+		return err
+	}
+	configDir := filepath.Join(l.Config.LogstashHome, "config")
+	files := map[string]string{
+		filepath.Join(configDir, settings.FileName): settings.FileName,
+		filepath.Join(configDir, pipelinesFileName): pipelinesFileName,
+		filepath.Join(configDir, jvm.FileName):      jvm.FileName,
+		filepath.Join(configDir, logging.FileName):  logging.FileName,
+	}
+	for _, file := range pipelineFiles {
+		files[file] = filepath.Join("logstash.conf.d", filepath.Base(file))
+	}
+	for src, dest := range files {
+		data, err := ioutil.ReadFile(src)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, dest), []byte(l.Redact(string(data))), 0644); err != nil {
+			return err
+		}
+	}
+
+	environment := os.Environ()
+	sort.Strings(environment)
+	if err := ioutil.WriteFile(filepath.Join(dir, "environment.txt"), []byte(l.Redact(strings.Join(environment, "\n"))+"\n"), 0644); err != nil {
+		return err
+	}
+
+	if prepareErr == nil {
+		l.Log.Info("testing the Logstash configuration (logstash -t) ...")
+		result := "configuration OK"
+		args, err := l.LogstashArgs()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(filepath.Join(l.Config.LogstashHome, "bin", "logstash"), append(args, "-t")...)
+		cmd.Dir = l.Config.Home
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			result = fmt.Sprintf("configuration test failed: %s", err.Error())
+		}
+		l.Log.Info("%s", result)
+		if err := ioutil.WriteFile(filepath.Join(dir, "config-test.log"), []byte(l.Redact(string(out)+"\n"+result+"\n")), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
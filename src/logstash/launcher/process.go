@@ -0,0 +1,160 @@
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// process types of the release step, the launcher is started with the process type as argument
+// (`bin/run.sh worker`), web is the default
+const (
+	ProcessWeb         = "web"
+	ProcessWorker      = "worker"
+	ProcessConfigCheck = "config-check"
+	ProcessCurator     = "curator"
+	ProcessCuratorTask = "curator-task"
+)
+
+// RunProcess runs the process type and returns its exit code
+func RunProcess(l *Launcher, processType string) (int, error) {
+	switch processType {
+	case "", ProcessWeb:
+		return Run(l)
+	case ProcessWorker:
+		return RunWorker(l)
+	case ProcessConfigCheck:
+		return RunConfigCheck(l)
+	case ProcessCurator:
+		return RunCurator(l)
+	case ProcessCuratorTask:
+		return RunCuratorTask(l)
+	}
+	return 2, fmt.Errorf("unknown process type '%s' (%s, %s, %s, %s or %s)", processType, ProcessWeb, ProcessWorker, ProcessConfigCheck, ProcessCurator, ProcessCuratorTask)
+}
+
+// RunWorker starts Logstash without a route: the health endpoint is not served (the port health
+// check of Cloud Foundry can't reach it) and Curator is left to the web or curator process
+func RunWorker(l *Launcher) (int, error) {
+	l.Config.HealthCheck.Enabled = false
+	l.Config.CuratorEnabled = false
+	return Run(l)
+}
+
+// RunConfigCheck prepares the runtime environment and tests the Logstash configuration
+// (`logstash -t`), the exit code is the one of Logstash. Meant to run as task before a restart:
+// `cf run-task <app> "bin/run.sh config-check"`
+func RunConfigCheck(l *Launcher) (int, error) {
+	l.Log.Info("CHECKING THE CONFIGURATION ...")
+
+	if l.Config.HealthCheck.Enabled {
+		if err := os.Setenv("PORT", fmt.Sprintf("%d", l.Config.HealthCheck.InternalPort)); err != nil {
+			return 1, err
+		}
+	}
+	if err := l.Prepare(); err != nil {
+		return 1, err
+	}
+
+	args, err := l.LogstashArgs()
+	if err != nil {
+		return 1, err
+	}
+	if err := l.command(filepath.Join(l.Config.LogstashHome, "bin", "logstash"), append(args, "-t")...).Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return 1, err
+		}
+		l.Log.Error("the Logstash configuration is invalid")
+		return ExitCode(err), nil
+	}
+	l.Log.Info("the Logstash configuration is valid")
+	return 0, nil
+}
+
+// RunCurator renders the Curator templates and runs Ofelia with the Curator schedule instead of
+// Logstash, the Logstash memory settings and pipelines are not prepared. Only the first instance
+// (CF_INSTANCE_INDEX 0) schedules Curator, further instances idle until they are stopped.
+func RunCurator(l *Launcher) (int, error) {
+	if !l.Config.CuratorEnabled {
+		return 1, fmt.Errorf("Curator is not installed, enable it with `curator.install` in the Logstash file")
+	}
+
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if l.Config.InstanceIndex != 0 {
+		l.Log.Info("Curator is scheduled by instance 0 of the curator process, instance %d idles", l.Config.InstanceIndex)
+		for sig := range signals {
+			if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+				return 0, nil
+			}
+		}
+		return 0, nil
+	}
+
+	l.Log.Info("STARTING CURATOR ...")
+	if err := l.PrepareCurator(); err != nil {
+		return 1, err
+	}
+
+	//stopped while preparing
+	select {
+	case sig := <-signals:
+		if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+			l.Log.Info("received %s during startup, Curator is not started", sig)
+			return 0, nil
+		}
+	default:
+	}
+
+	l.Log.Info("running Curator once to create the Logstash index for today")
+	if err := l.command(filepath.Join(l.Config.Home, "bin", "curator.sh")).Run(); err != nil {
+		l.Log.Warning("Curator failed: %s", err.Error())
+	}
+
+	supervisor := NewSupervisor(l.Log, signals)
+	if l.Config.DrainTimeout > 0 {
+		supervisor.DrainTimeout = l.Config.DrainTimeout
+	}
+	supervisor.MainSignals = helperSignals //Ofelia
+	ofelia := l.command(filepath.Join(l.Config.OfeliaHome, "ofelia"), "daemon", "--config", filepath.Join(l.Config.Home, "ofelia", "schedule.ini"))
+	ofelia.Stderr = l.Stdout
+	if err := supervisor.StartMain("Ofelia", ofelia); err != nil {
+		return 1, err
+	}
+	return supervisor.Wait()
+}
+
+// RunCuratorTask runs Curator once and returns its exit code, it's the command of bin/curator-task:
+// `cf run-task <app> bin/curator-task`
+func RunCuratorTask(l *Launcher) (int, error) {
+	if !l.Config.CuratorEnabled {
+		return 1, fmt.Errorf("Curator is not installed, enable it with `curator.install` in the Logstash file")
+	}
+
+	l.Log.Info("RUNNING CURATOR ...")
+	if err := l.PrepareCurator(); err != nil {
+		return 1, err
+	}
+	if err := l.command(filepath.Join(l.Config.Home, "bin", "curator.sh")).Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return 1, err
+		}
+		l.Log.Error("Curator failed: %s", err.Error())
+		return ExitCode(err), nil
+	}
+	l.Log.Info("Curator finished")
+	return 0, nil
+}
+
+// PrepareCurator renders the templates used by Curator and Ofelia
+func (l *Launcher) PrepareCurator() error {
+	if err := l.PrepareDirectories(); err != nil {
+		return fmt.Errorf("unable to prepare runtime directories: %s", err.Error())
+	}
+	return l.RenderTemplates()
+}
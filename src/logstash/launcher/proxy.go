@@ -0,0 +1,148 @@
+package launcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/andibrunner/libbuildpack"
+)
+
+// time a client gets to send the request line before the connection is handed to Logstash
+const requestLineTimeout = 5 * time.Second
+
+// HealthProxy listens on the app port ($PORT) and forwards the connections to the input of
+// Logstash on the internal port. Connections starting with `GET <Path>` are answered with the
+// health of Logstash instead, so CF's http health check works with every input (syslog, http, ...).
+type HealthProxy struct {
+	Path   string
+	Target string
+	Health func() Health
+	Log    *libbuildpack.Logger
+
+	listener net.Listener
+}
+
+func NewHealthProxy(path string, targetPort int, health func() Health, logger *libbuildpack.Logger) *HealthProxy {
+	return &HealthProxy{
+		Path:   path,
+		Target: fmt.Sprintf("127.0.0.1:%d", targetPort),
+		Health: health,
+		Log:    logger,
+	}
+}
+
+// Listen binds the address, the connections are served by Serve
+func (p *HealthProxy) Listen(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+	return nil
+}
+
+// Addr returns the address the proxy listens on
+func (p *HealthProxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Serve accepts connections until Close is called
+func (p *HealthProxy) Serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+// Close stops accepting connections, open connections are not interrupted
+func (p *HealthProxy) Close() error {
+	return p.listener.Close()
+}
+
+func (p *HealthProxy) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(requestLineTimeout))
+	if p.isHealthCheck(reader) {
+		//read the request headers, closing the connection with unread data resets it
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil || strings.TrimSpace(line) == "" {
+				break
+			}
+		}
+		p.respond(conn)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	target, err := net.Dial("tcp", p.Target)
+	if err != nil {
+		p.Log.Warning("health-check: Logstash input %s not reachable: %s", p.Target, err.Error())
+		return
+	}
+	defer target.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		//the bytes read while looking for the health check are still buffered
+		io.Copy(target, reader)
+		if tcp, ok := target.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, target)
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+// isHealthCheck compares the request line byte by byte, it returns at the first mismatch
+// so that protocols which don't start with `GET` are forwarded without delay
+func (p *HealthProxy) isHealthCheck(reader *bufio.Reader) bool {
+	prefix := "GET " + p.Path
+	for i := 1; i <= len(prefix); i++ {
+		peeked, err := reader.Peek(i)
+		if err != nil || peeked[i-1] != prefix[i-1] {
+			return false
+		}
+	}
+
+	peeked, err := reader.Peek(len(prefix) + 1)
+	if err != nil {
+		return false
+	}
+	next := peeked[len(prefix)]
+	return next == ' ' || next == '?'
+}
+
+func (p *HealthProxy) respond(conn net.Conn) {
+	health := p.Health()
+
+	status := "200 OK"
+	if !health.Healthy {
+		status = "503 Service Unavailable"
+	}
+	body, err := json.Marshal(health)
+	if err != nil {
+		body = []byte("{}")
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(requestLineTimeout))
+	fmt.Fprintf(conn, "HTTP/1.1 %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", status, len(body), body)
+}
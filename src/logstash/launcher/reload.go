@@ -0,0 +1,125 @@
+package launcher
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Reloader renders the pipeline templates while Logstash is running. Changed files are
+// replaced in logstash.conf.d, Logstash reloads them (--config.reload.automatic).
+type Reloader struct {
+	Launcher *Launcher
+	mutex    sync.Mutex
+}
+
+func NewReloader(l *Launcher) *Reloader {
+	return &Reloader{Launcher: l}
+}
+
+// Reload renders the templates and updates logstash.conf.d, it returns whether a file changed
+func (r *Reloader) Reload() (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	home := r.Launcher.Config.Home
+	rendered := filepath.Join(home, ".logstash.conf.d.reload")
+	if err := os.RemoveAll(rendered); err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(rendered)
+	if err := os.MkdirAll(rendered, 0755); err != nil {
+		return false, err
+	}
+
+	for _, args := range r.Launcher.pipelineRenderings(rendered) {
+		if err := r.Launcher.gte(args...); err != nil {
+			return false, err
+		}
+	}
+	return syncDir(rendered, filepath.Join(home, "logstash.conf.d"))
+}
+
+// Poll reloads every interval until stop is closed
+func (r *Reloader) Poll(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.logReload(r.Reload())
+		}
+	}
+}
+
+// Hangup is called by the supervisor on SIGHUP before it's forwarded to Logstash
+func (r *Reloader) Hangup() {
+	r.Launcher.Log.Info("re-rendering the pipeline templates ...")
+	r.logReload(r.Reload())
+}
+
+func (r *Reloader) logReload(changed bool, err error) {
+	if err != nil {
+		r.Launcher.Log.Warning("reload failed, Logstash keeps the current pipeline: %s", err.Error())
+	} else if changed {
+		r.Launcher.Log.Info("pipeline configuration changed, Logstash reloads it")
+	}
+}
+
+// syncDir makes the files of dest equal to the files of src, every file is replaced by a
+// rename so that Logstash never reads a partially written file
+func syncDir(src string, dest string) (bool, error) {
+	changed := false
+
+	srcFiles, err := ioutil.ReadDir(src)
+	if err != nil {
+		return false, err
+	}
+	keep := make(map[string]bool)
+	for _, file := range srcFiles {
+		if file.IsDir() {
+			continue
+		}
+		keep[file.Name()] = true
+
+		data, err := ioutil.ReadFile(filepath.Join(src, file.Name()))
+		if err != nil {
+			return changed, err
+		}
+		current, err := ioutil.ReadFile(filepath.Join(dest, file.Name()))
+		if err == nil && bytes.Equal(current, data) {
+			continue
+		} else if err != nil && !os.IsNotExist(err) {
+			return changed, err
+		}
+
+		tmpFile := filepath.Join(dest, "."+file.Name()+".tmp")
+		if err := ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+			return changed, err
+		}
+		if err := os.Rename(tmpFile, filepath.Join(dest, file.Name())); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	destFiles, err := ioutil.ReadDir(dest)
+	if err != nil {
+		return changed, err
+	}
+	for _, file := range destFiles {
+		if file.IsDir() || keep[file.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dest, file.Name())); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
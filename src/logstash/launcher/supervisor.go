@@ -0,0 +1,235 @@
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/andibrunner/libbuildpack"
+)
+
+// signals which are forwarded to the main process (Logstash)
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// signals which are forwarded to the helpers, the others (e.g. SIGHUP) would terminate Ofelia
+var helperSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+
+// time the helpers get to shut down after the main process exited
+const helperStopTimeout = 10 * time.Second
+
+// DefaultDrainTimeout leaves a margin to the 10 seconds Cloud Foundry waits before it kills the container
+const DefaultDrainTimeout = 8 * time.Second
+
+type process struct {
+	name string
+	cmd  *exec.Cmd
+	done chan error
+}
+
+type exit struct {
+	process *process
+	err     error
+}
+
+// Supervisor runs a main process (Logstash) and helper processes (Ofelia). MainSignals are
+// forwarded to the main process, only SIGTERM and SIGINT to the helpers. If a helper dies the
+// main process is stopped as well. After SIGTERM or SIGINT the main process gets DrainTimeout to
+// shut down before it's killed. OnHangup is called on SIGHUP before the signal is forwarded.
+type Supervisor struct {
+	Log          *libbuildpack.Logger
+	DrainTimeout time.Duration
+	MainSignals  []os.Signal
+	OnHangup     func()
+	signals      <-chan os.Signal
+	main         *process
+	helpers      []*process
+	exits        chan exit
+}
+
+func NewSupervisor(logger *libbuildpack.Logger, signals <-chan os.Signal) *Supervisor {
+	return &Supervisor{Log: logger, DrainTimeout: DefaultDrainTimeout, MainSignals: forwardedSignals, signals: signals, exits: make(chan exit, 8)}
+}
+
+// StartHelper starts a process which runs next to the main process
+func (s *Supervisor) StartHelper(name string, cmd *exec.Cmd) error {
+	p, err := s.start(name, cmd)
+	if err != nil {
+		return err
+	}
+	s.helpers = append(s.helpers, p)
+	return nil
+}
+
+// StartMain starts the process whose exit code becomes the exit code of the launcher
+func (s *Supervisor) StartMain(name string, cmd *exec.Cmd) error {
+	p, err := s.start(name, cmd)
+	if err != nil {
+		return err
+	}
+	s.main = p
+	return nil
+}
+
+// Wait blocks until the main process exited and returns its exit code. If a helper exits
+// first, the main process is terminated and an error is returned.
+func (s *Supervisor) Wait() (int, error) {
+	var (
+		helperErr  error
+		stopping   bool
+		stopStart  time.Time
+		drainTimer <-chan time.Time
+		killed     bool
+	)
+
+	for {
+		select {
+		case sig := <-s.signals:
+			if (sig == syscall.SIGTERM || sig == syscall.SIGINT) && !stopping {
+				stopping = true
+				stopStart = time.Now()
+				drainTimer = time.After(s.DrainTimeout)
+				s.Log.Info("received %s, stopping %s (drain timeout %s)", sig, s.main.name, s.DrainTimeout)
+			} else {
+				if sig == syscall.SIGHUP && s.OnHangup != nil {
+					s.OnHangup()
+				}
+				if containsSignal(s.MainSignals, sig) {
+					s.Log.Info("received %s, forwarding it to %s", sig, s.main.name)
+				} else {
+					s.Log.Info("received %s, ignoring it", sig)
+				}
+			}
+			s.signal(sig)
+
+		case <-drainTimer:
+			s.Log.Warning("%s did not stop within the drain timeout of %s, killing it", s.main.name, s.DrainTimeout)
+			killed = true
+			s.main.cmd.Process.Kill()
+
+		case e := <-s.exits:
+			if e.process == s.main {
+				code := ExitCode(e.err)
+				switch {
+				case killed:
+					s.Log.Warning("%s was killed, events in memory queues may be lost", e.process.name)
+				case stopping:
+					s.Log.Info("%s stopped after %s with code %d", e.process.name, time.Since(stopStart).Round(100*time.Millisecond), code)
+				default:
+					s.Log.Info("%s exited with code %d", e.process.name, code)
+				}
+
+				timeout := helperStopTimeout
+				if stopping {
+					timeout = s.DrainTimeout - time.Since(stopStart)
+					if timeout < time.Second {
+						timeout = time.Second
+					}
+				}
+				s.stopHelpers(timeout)
+
+				if helperErr != nil {
+					return 1, helperErr
+				}
+				return code, nil
+			}
+
+			s.removeHelper(e.process)
+			if stopping {
+				s.Log.Info("%s stopped", e.process.name)
+			} else if helperErr == nil {
+				helperErr = fmt.Errorf("%s exited unexpectedly with code %d", e.process.name, ExitCode(e.err))
+				s.Log.Error("%s, stopping %s", helperErr.Error(), s.main.name)
+				stopping = true
+				stopStart = time.Now()
+				drainTimer = time.After(s.DrainTimeout)
+				s.main.cmd.Process.Signal(syscall.SIGTERM)
+			}
+		}
+	}
+}
+
+// Stop terminates all running processes, used if the startup fails half way
+func (s *Supervisor) Stop() {
+	s.stopHelpers(helperStopTimeout)
+}
+
+func (s *Supervisor) start(name string, cmd *exec.Cmd) (*process, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start %s: %s", name, err.Error())
+	}
+
+	p := &process{name: name, cmd: cmd, done: make(chan error, 1)}
+	go func() {
+		err := cmd.Wait()
+		p.done <- err
+		s.exits <- exit{process: p, err: err}
+	}()
+	return p, nil
+}
+
+func (s *Supervisor) signal(sig os.Signal) {
+	processes := []*process{}
+	if containsSignal(helperSignals, sig) {
+		processes = append(processes, s.helpers...)
+	}
+	if s.main != nil && containsSignal(s.MainSignals, sig) {
+		processes = append(processes, s.main)
+	}
+	for _, p := range processes {
+		if err := p.cmd.Process.Signal(sig); err != nil {
+			s.Log.Debug("unable to send %s to %s: %s", sig, p.name, err.Error())
+		}
+	}
+}
+
+func containsSignal(signals []os.Signal, sig os.Signal) bool {
+	for _, s := range signals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Supervisor) removeHelper(p *process) {
+	for i, helper := range s.helpers {
+		if helper == p {
+			s.helpers = append(s.helpers[:i], s.helpers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Supervisor) stopHelpers(timeout time.Duration) {
+	for _, p := range s.helpers {
+		p.cmd.Process.Signal(syscall.SIGTERM)
+	}
+	deadline := time.Now().Add(timeout)
+	for _, p := range s.helpers {
+		select {
+		case <-p.done:
+		case <-time.After(deadline.Sub(time.Now())):
+			s.Log.Warning("%s did not stop within %s, killing it", p.name, timeout)
+			p.cmd.Process.Kill()
+		}
+	}
+	s.helpers = nil
+}
+
+// ExitCode returns the exit code of a finished command, 128+n if it was killed by signal n
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() {
+				return 128 + int(status.Signal())
+			}
+			return status.ExitStatus()
+		}
+	}
+	return 1
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FileName of the log4j2 configuration of Logstash in $LOGSTASH_HOME/config
+const FileName = "log4j2.properties"
+
+// formats of `log.format`
+const (
+	FormatPlain = "plain"
+	FormatJSON  = "json"
+)
+
+// log levels of log4j2 accepted by Logstash
+var levels = []string{"fatal", "error", "warn", "info", "debug", "trace"}
+
+// time values of the slowlog thresholds, e.g. `500ms` or `2s`
+var timeValuePattern = regexp.MustCompile(`^\d+\s*(nanos|micros|ms|s|m|h|d)$`)
+
+var loggerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.$\-]+$`)
+
+// Options are the settings of the `logging:` section of the Logstash file
+type Options struct {
+	Format  string
+	Level   string
+	Loggers map[string]string // level per logger, e.g. `logstash.outputs.elasticsearch: debug`
+	Slowlog map[string]string // threshold per slowlog level, e.g. `warn: 2s`
+}
+
+// Validate checks the format, the levels and the slowlog thresholds
+func (o Options) Validate() error {
+	if o.Format != "" && o.Format != FormatPlain && o.Format != FormatJSON {
+		return fmt.Errorf("invalid format '%s' (%s or %s)", o.Format, FormatPlain, FormatJSON)
+	}
+	if o.Level != "" && !validLevel(o.Level) {
+		return fmt.Errorf("invalid level '%s' (%s)", o.Level, strings.Join(levels, ", "))
+	}
+	for name, level := range o.Loggers {
+		if !loggerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid logger name '%s'", name)
+		}
+		if !validLevel(level) {
+			return fmt.Errorf("invalid level '%s' of logger '%s' (%s)", level, name, strings.Join(levels, ", "))
+		}
+	}
+	for level, threshold := range o.Slowlog {
+		if level != "warn" && level != "info" && level != "debug" && level != "trace" {
+			return fmt.Errorf("invalid slowlog level '%s' (warn, info, debug or trace)", level)
+		}
+		if !timeValuePattern.MatchString(strings.TrimSpace(threshold)) {
+			return fmt.Errorf("invalid slowlog threshold '%s' (e.g. 500ms or 2s)", threshold)
+		}
+	}
+	return nil
+}
+
+// Settings returns the logstash.yml settings of the options
+func (o Options) Settings() map[string]interface{} {
+	settings := map[string]interface{}{}
+	if o.Format != "" {
+		settings["log.format"] = o.Format
+	}
+	if o.Level != "" {
+		settings["log.level"] = strings.ToLower(o.Level)
+	}
+	for level, threshold := range o.Slowlog {
+		settings["slowlog.threshold."+level] = strings.TrimSpace(threshold)
+	}
+	return settings
+}
+
+// Properties returns the log4j2 configuration. All logs, including the slowlog, go to stdout in the
+// format selected by `log.format`, the root level follows `log.level`.
+func (o Options) Properties() string {
+	lines := []string{
+		"status = error",
+		"name = LogstashPropertiesConfig",
+		"",
+		"appender.console.type = Console",
+		"appender.console.name = plain_console",
+		"appender.console.layout.type = PatternLayout",
+		"appender.console.layout.pattern = [%d{ISO8601}][%-5p][%-25c] %m%n",
+		"",
+		"appender.json_console.type = Console",
+		"appender.json_console.name = json_console",
+		"appender.json_console.layout.type = JSONLayout",
+		"appender.json_console.layout.compact = true",
+		"appender.json_console.layout.eventEol = true",
+		"",
+		"rootLogger.level = ${sys:ls.log.level}",
+		"rootLogger.appenderRef.console.ref = ${sys:ls.log.format}_console",
+		"",
+		"logger.slowlog.name = slowlog",
+		"logger.slowlog.level = trace",
+		"logger.slowlog.appenderRef.console_slowlog.ref = ${sys:ls.log.format}_console",
+		"logger.slowlog.additivity = false",
+	}
+
+	names := make([]string, 0, len(o.Loggers))
+	for name := range o.Loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		lines = append(lines,
+			"",
+			fmt.Sprintf("logger.buildpack_%d.name = %s", i, name),
+			fmt.Sprintf("logger.buildpack_%d.level = %s", i, strings.ToLower(o.Loggers[name])))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Render writes log4j2.properties to $LOGSTASH_HOME/config
+func (o Options) Render(logstashHome string) error {
+	return ioutil.WriteFile(filepath.Join(logstashHome, "config", FileName), []byte(o.Properties()), 0644)
+}
+
+func validLevel(level string) bool {
+	for _, valid := range levels {
+		if strings.ToLower(level) == valid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package logging_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"logstash/logging"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logging", func() {
+	Describe("Validate", func() {
+		It("accepts valid options", func() {
+			options := logging.Options{
+				Format:  "json",
+				Level:   "WARN",
+				Loggers: map[string]string{"logstash.outputs.elasticsearch": "debug"},
+				Slowlog: map[string]string{"warn": "2s", "info": "500ms"},
+			}
+			Expect(options.Validate()).To(Succeed())
+		})
+
+		It("refuses unknown formats and levels", func() {
+			Expect(logging.Options{Format: "xml"}.Validate()).NotTo(Succeed())
+			Expect(logging.Options{Level: "verbose"}.Validate()).NotTo(Succeed())
+			Expect(logging.Options{Loggers: map[string]string{"logstash.outputs": "loud"}}.Validate()).NotTo(Succeed())
+			Expect(logging.Options{Loggers: map[string]string{"logstash outputs": "debug"}}.Validate()).NotTo(Succeed())
+		})
+
+		It("refuses invalid slowlog thresholds", func() {
+			Expect(logging.Options{Slowlog: map[string]string{"warn": "2 seconds"}}.Validate()).NotTo(Succeed())
+			Expect(logging.Options{Slowlog: map[string]string{"error": "2s"}}.Validate()).NotTo(Succeed())
+		})
+	})
+
+	Describe("Settings", func() {
+		It("returns the logstash.yml settings", func() {
+			options := logging.Options{Format: "json", Level: "WARN", Slowlog: map[string]string{"warn": " 2s"}}
+			Expect(options.Settings()).To(Equal(map[string]interface{}{
+				"log.format":             "json",
+				"log.level":              "warn",
+				"slowlog.threshold.warn": "2s",
+			}))
+		})
+	})
+
+	Describe("Properties", func() {
+		It("logs to the console in the format of log.format", func() {
+			properties := logging.Options{}.Properties()
+			Expect(properties).To(ContainSubstring("rootLogger.appenderRef.console.ref = ${sys:ls.log.format}_console\n"))
+			Expect(properties).To(ContainSubstring("appender.json_console.layout.type = JSONLayout\n"))
+			Expect(properties).To(ContainSubstring("logger.slowlog.appenderRef.console_slowlog.ref = ${sys:ls.log.format}_console\n"))
+			Expect(properties).NotTo(ContainSubstring("RollingFile"))
+		})
+
+		It("sets the level of the loggers", func() {
+			properties := logging.Options{Loggers: map[string]string{"org.logstash": "TRACE", "logstash.inputs.beats": "debug"}}.Properties()
+			Expect(properties).To(HaveSuffix("\nlogger.buildpack_0.name = logstash.inputs.beats\nlogger.buildpack_0.level = debug\n\nlogger.buildpack_1.name = org.logstash\nlogger.buildpack_1.level = trace\n"))
+		})
+	})
+
+	Describe("Render", func() {
+		It("writes log4j2.properties into the config directory", func() {
+			logstashHome, err := ioutil.TempDir("", "logging")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(logstashHome)
+			Expect(os.MkdirAll(filepath.Join(logstashHome, "config"), 0755)).To(Succeed())
+
+			Expect(logging.Options{}.Render(logstashHome)).To(Succeed())
+			data, err := ioutil.ReadFile(filepath.Join(logstashHome, "config", logging.FileName))
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(Equal(logging.Options{}.Properties()))
+		})
+	})
+})
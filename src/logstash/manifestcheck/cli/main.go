@@ -0,0 +1,43 @@
+package main
+
+import (
+	"logstash/manifestcheck"
+	"os"
+	"time"
+
+	"github.com/andibrunner/libbuildpack"
+)
+
+func main() {
+	logger := libbuildpack.NewLogger(os.Stdout)
+
+	var buildpackDir string
+	if len(os.Args) > 1 {
+		buildpackDir = os.Args[1]
+	} else {
+		var err error
+		buildpackDir, err = libbuildpack.GetBuildpackDir()
+		if err != nil {
+			logger.Error("Unable to determine buildpack directory: %s", err.Error())
+			os.Exit(8)
+		}
+	}
+
+	manifest, err := libbuildpack.NewManifest(buildpackDir, logger, time.Now())
+	if err != nil {
+		logger.Error("Unable to load buildpack manifest: %s", err.Error())
+		os.Exit(9)
+	}
+
+	logger.BeginStep("Checking %s/manifest.yml", buildpackDir)
+	problems := manifestcheck.Check(manifest)
+	for _, p := range problems {
+		logger.Error("%s", p.String())
+	}
+
+	if len(problems) > 0 {
+		logger.Error("%d problem(s) found in manifest.yml", len(problems))
+		os.Exit(1)
+	}
+	logger.Info("manifest.yml is consistent")
+}
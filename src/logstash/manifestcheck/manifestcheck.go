@@ -0,0 +1,171 @@
+package manifestcheck
+
+import (
+	"fmt"
+	"github.com/andibrunner/libbuildpack"
+	"regexp"
+	"sort"
+)
+
+// dependencies which are installed with the same version as Logstash (see supply.InstallDependencyXPack)
+var logstashBundles = []string{"x-pack", "logstash-plugins"}
+
+var sha256Format = regexp.MustCompile("^[0-9a-f]{64}$")
+
+type Problem struct {
+	Dependency string
+	Message    string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Dependency, p.Message)
+}
+
+// Check validates the buildpack manifest and returns all problems found
+func Check(m *libbuildpack.Manifest) []Problem {
+	problems := []Problem{}
+
+	problems = append(problems, checkEntries(m)...)
+	problems = append(problems, checkDefaultVersions(m)...)
+	problems = append(problems, checkLogstashBundles(m)...)
+	problems = append(problems, checkStacks(m)...)
+
+	return problems
+}
+
+func checkEntries(m *libbuildpack.Manifest) []Problem {
+	problems := []Problem{}
+	seen := make(map[string]bool)
+
+	for _, e := range m.ManifestEntries {
+		name := e.Dependency.Name
+		id := fmt.Sprintf("%s %s", name, e.Dependency.Version)
+
+		if e.Dependency.Version == "" {
+			problems = append(problems, Problem{name, "dependency without version"})
+		}
+		if seen[id] {
+			problems = append(problems, Problem{name, fmt.Sprintf("version %s is defined more than once", e.Dependency.Version)})
+		}
+		seen[id] = true
+
+		if e.URI == "" {
+			problems = append(problems, Problem{name, fmt.Sprintf("version %s has no uri", e.Dependency.Version)})
+		}
+		if !sha256Format.MatchString(e.SHA256) {
+			problems = append(problems, Problem{name, fmt.Sprintf("version %s has a malformed sha256 '%s' (expected 64 lowercase hex characters)", e.Dependency.Version, e.SHA256)})
+		}
+		if len(e.CFStacks) == 0 {
+			problems = append(problems, Problem{name, fmt.Sprintf("version %s has no cf_stacks", e.Dependency.Version)})
+		}
+	}
+
+	return problems
+}
+
+func checkDefaultVersions(m *libbuildpack.Manifest) []Problem {
+	problems := []Problem{}
+	count := make(map[string]int)
+
+	for _, d := range m.DefaultVersions {
+		count[d.Name]++
+		if count[d.Name] == 2 {
+			problems = append(problems, Problem{d.Name, "more than one default version"})
+		}
+
+		if _, err := libbuildpack.FindMatchingVersion(d.Version, m.AllDependencyVersions(d.Name)); err != nil {
+			problems = append(problems, Problem{d.Name, fmt.Sprintf("default version %s does not resolve: %s", d.Version, err.Error())})
+		}
+	}
+
+	for _, e := range m.ManifestEntries {
+		if count[e.Dependency.Name] == 0 {
+			problems = append(problems, Problem{e.Dependency.Name, "no default version"})
+			count[e.Dependency.Name] = -1
+		}
+	}
+
+	return problems
+}
+
+func checkLogstashBundles(m *libbuildpack.Manifest) []Problem {
+	problems := []Problem{}
+
+	// Logstash versions which can be requested in the Logstash file
+	for _, version := range m.AllDependencyVersions("logstash") {
+		for _, bundle := range logstashBundles {
+			if !contains(m.AllDependencyVersions(bundle), version) {
+				problems = append(problems, Problem{bundle, fmt.Sprintf("no version matching logstash %s", version)})
+			}
+		}
+	}
+
+	// default versions are used when the Logstash file defines no version
+	defaultLogstash, err := defaultVersion(m, "logstash")
+	if err != nil {
+		return problems
+	}
+	for _, bundle := range logstashBundles {
+		defaultBundle, err := defaultVersion(m, bundle)
+		if err == nil && defaultBundle != defaultLogstash {
+			problems = append(problems, Problem{bundle, fmt.Sprintf("default version %s does not match default logstash version %s", defaultBundle, defaultLogstash)})
+		}
+	}
+
+	return problems
+}
+
+func checkStacks(m *libbuildpack.Manifest) []Problem {
+	problems := []Problem{}
+
+	stacks := make(map[string]bool)
+	for _, e := range m.ManifestEntries {
+		for _, s := range e.CFStacks {
+			stacks[s] = true
+		}
+	}
+
+	// every default dependency has to be available on every stack the buildpack supports
+	for _, stack := range sortedKeys(stacks) {
+		for _, d := range m.DefaultVersions {
+			versions := []string{}
+			for _, e := range m.ManifestEntries {
+				if e.Dependency.Name == d.Name && contains(e.CFStacks, stack) {
+					versions = append(versions, e.Dependency.Version)
+				}
+			}
+			if _, err := libbuildpack.FindMatchingVersion(d.Version, versions); err != nil {
+				problems = append(problems, Problem{d.Name, fmt.Sprintf("default version %s is not available for stack %s", d.Version, stack)})
+			}
+		}
+	}
+
+	return problems
+}
+
+func defaultVersion(m *libbuildpack.Manifest, name string) (string, error) {
+	for _, d := range m.DefaultVersions {
+		if d.Name == name {
+			return libbuildpack.FindMatchingVersion(d.Version, m.AllDependencyVersions(name))
+		}
+	}
+	return "", fmt.Errorf("no default version for %s", name)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,13 @@
+package manifestcheck_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestManifestcheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Manifestcheck Suite")
+}
@@ -0,0 +1,90 @@
+package manifestcheck_test
+
+import (
+	"logstash/manifestcheck"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const validSha256 = "5a5a03684fd9b3582c3982c208f14b37d8f4ca061911e2cccc51332bca80fd70"
+
+func entry(name, version string, stacks ...string) libbuildpack.ManifestEntry {
+	if len(stacks) == 0 {
+		stacks = []string{"cflinuxfs2"}
+	}
+	return libbuildpack.ManifestEntry{
+		Dependency: libbuildpack.Dependency{Name: name, Version: version},
+		URI:        "https://example.com/" + name + "-" + version + ".tar.gz",
+		SHA256:     validSha256,
+		CFStacks:   stacks,
+	}
+}
+
+func messages(problems []manifestcheck.Problem) []string {
+	result := []string{}
+	for _, p := range problems {
+		result = append(result, p.String())
+	}
+	return result
+}
+
+var _ = Describe("Check", func() {
+	var manifest *libbuildpack.Manifest
+
+	BeforeEach(func() {
+		manifest = &libbuildpack.Manifest{
+			DefaultVersions: []libbuildpack.Dependency{
+				{Name: "logstash", Version: "6.1.x"},
+				{Name: "logstash-plugins", Version: "6.1.x"},
+				{Name: "x-pack", Version: "6.1.3"},
+				{Name: "jq", Version: "1.5"},
+			},
+			ManifestEntries: []libbuildpack.ManifestEntry{
+				entry("logstash", "6.1.3"),
+				entry("logstash-plugins", "6.1.3"),
+				entry("x-pack", "6.1.3"),
+				entry("jq", "1.5"),
+			},
+		}
+	})
+
+	It("accepts a consistent manifest", func() {
+		Expect(manifestcheck.Check(manifest)).To(BeEmpty())
+	})
+
+	It("reports default versions which do not resolve", func() {
+		manifest.DefaultVersions[3].Version = "1.6.x"
+		Expect(messages(manifestcheck.Check(manifest))).To(ContainElement(ContainSubstring("jq: default version 1.6.x does not resolve")))
+	})
+
+	It("reports dependencies without default version", func() {
+		manifest.ManifestEntries = append(manifest.ManifestEntries, entry("ofelia", "0.2.2"))
+		Expect(messages(manifestcheck.Check(manifest))).To(ContainElement("ofelia: no default version"))
+	})
+
+	It("reports logstash versions without x-pack and plugin bundles", func() {
+		manifest.ManifestEntries = append(manifest.ManifestEntries, entry("logstash", "6.1.4"))
+		Expect(messages(manifestcheck.Check(manifest))).To(ConsistOf(
+			"x-pack: no version matching logstash 6.1.4",
+			"logstash-plugins: no version matching logstash 6.1.4",
+			"logstash-plugins: default version 6.1.3 does not match default logstash version 6.1.4",
+			"x-pack: default version 6.1.3 does not match default logstash version 6.1.4",
+		))
+	})
+
+	It("reports malformed sha256 fields", func() {
+		manifest.ManifestEntries[3].SHA256 = "abc"
+		Expect(messages(manifestcheck.Check(manifest))).To(ConsistOf(ContainSubstring("jq: version 1.5 has a malformed sha256 'abc'")))
+	})
+
+	It("reports default dependencies missing on a stack", func() {
+		manifest.ManifestEntries[0].CFStacks = []string{"cflinuxfs2", "cflinuxfs3"}
+		Expect(messages(manifestcheck.Check(manifest))).To(ConsistOf(
+			"logstash-plugins: default version 6.1.x is not available for stack cflinuxfs3",
+			"x-pack: default version 6.1.3 is not available for stack cflinuxfs3",
+			"jq: default version 1.5 is not available for stack cflinuxfs3",
+		))
+	})
+})
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CgroupDir is the mount point of the cgroup file system in the container
+const CgroupDir = "/sys/fs/cgroup"
+
+// AvailableCPUs returns the CPUs of the container the way the JVM determines them, and with
+// them the default of `pipeline.workers`: the CFS quota, else the CPU shares (1024 per CPU), else
+// the CPUs of the host. It's at least 1.
+func AvailableCPUs(cgroupDir string) int {
+	//cgroup v2
+	if fields := strings.Fields(readCgroupFile(cgroupDir, "cpu.max")); len(fields) == 2 && fields[0] != "max" {
+		if cpus := divideUp(fields[0], fields[1]); cpus > 0 {
+			return cpus
+		}
+	}
+
+	//cgroup v1
+	if cpus := divideUp(readCgroupFile(cgroupDir, "cpu", "cpu.cfs_quota_us"), readCgroupFile(cgroupDir, "cpu", "cpu.cfs_period_us")); cpus > 0 {
+		return cpus
+	}
+	//1024 shares are the default of a cgroup without CPU limit
+	if shares := readCgroupFile(cgroupDir, "cpu", "cpu.shares"); shares != "" && shares != "1024" {
+		if cpus := divideUp(shares, "1024"); cpus > 0 {
+			return cpus
+		}
+	}
+
+	return runtime.NumCPU()
+}
+
+func readCgroupFile(elem ...string) string {
+	data, err := ioutil.ReadFile(filepath.Join(elem...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// divideUp returns a / b rounded up, 0 if one of them is invalid or not positive
+func divideUp(a, b string) int {
+	x, err := strconv.Atoi(a)
+	if err != nil || x <= 0 {
+		return 0
+	}
+	y, err := strconv.Atoi(b)
+	if err != nil || y <= 0 {
+		return 0
+	}
+	return (x + y - 1) / y
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// classes loaded by Logstash 6 with the default plugins (JRuby compiles to many small classes)
+	loadedClasses = 20000
+	// metaspace per loaded class and the fixed JVM overhead, as estimated by the Java buildpack
+	metaspaceBytesPerClass = 5800
+	metaspaceOverhead      = 14
+
+	// threads of the JVM, JRuby, the inputs and the web API which don't depend on the workers
+	baseThreads = 50
+	// threads per pipeline worker (the worker and the batch/output threads started with it)
+	threadsPerWorker = 2
+
+	DefaultStackSize    = 1024 // KB
+	DefaultDirectMemory = 64   // MB
+	DefaultCodeCache    = 64   // MB
+
+	// the default direct memory and code cache shrink down to these sizes if the heap would be
+	// smaller than MinHeap otherwise (e.g. with 512M)
+	MinDirectMemory = 16 // MB
+	MinCodeCache    = 32 // MB
+
+	// Logstash doesn't start reliably with less heap
+	MinHeap = 256 // MB
+)
+
+// Settings are the inputs of the calculation, zero values are replaced by defaults
+type Settings struct {
+	Limit          int // container memory limit in MB
+	Reserved       int // MB not used by any JVM memory pool (native memory, OS)
+	HeapPercentage int // percentage of the remaining memory used for the heap
+	Workers        int // pipeline workers
+	Threads        int // thread count, default derived from the workers
+	StackSize      int // KB per thread
+	Metaspace      int // MB, default derived from the loaded classes
+	DirectMemory   int // MB
+	CodeCache      int // MB
+}
+
+// Result is the memory layout of the JVM, all sizes in MB except StackSize (KB)
+type Result struct {
+	Limit        int
+	Reserved     int
+	Heap         int
+	Metaspace    int
+	DirectMemory int
+	CodeCache    int
+	Threads      int
+	StackSize    int
+}
+
+// Calculate splits the container memory limit into the JVM memory pools. The non heap pools
+// (metaspace, direct memory, code cache, thread stacks) and the reserved memory are subtracted
+// from the limit, the heap gets HeapPercentage of the remaining memory.
+func Calculate(s Settings) (Result, error) {
+	if s.Limit <= 0 {
+		return Result{}, errors.New("memory limit is unknown")
+	}
+	if s.HeapPercentage <= 0 || s.HeapPercentage > 100 {
+		return Result{}, fmt.Errorf("heap percentage must be between 1 and 100, not %d", s.HeapPercentage)
+	}
+	values := []struct {
+		name  string
+		value int
+	}{{"reserved memory", s.Reserved}, {"workers", s.Workers}, {"threads", s.Threads}, {"stack size", s.StackSize}, {"metaspace", s.Metaspace}, {"direct memory", s.DirectMemory}, {"code cache", s.CodeCache}}
+	for _, v := range values {
+		if v.value < 0 {
+			return Result{}, fmt.Errorf("%s must not be negative", v.name)
+		}
+	}
+
+	r := Result{
+		Limit:        s.Limit,
+		Reserved:     s.Reserved,
+		Metaspace:    s.Metaspace,
+		DirectMemory: defaultInt(s.DirectMemory, DefaultDirectMemory),
+		CodeCache:    defaultInt(s.CodeCache, DefaultCodeCache),
+		Threads:      s.Threads,
+		StackSize:    defaultInt(s.StackSize, DefaultStackSize),
+	}
+	if r.Metaspace == 0 {
+		r.Metaspace = (loadedClasses*metaspaceBytesPerClass+1024*1024-1)/(1024*1024) + metaspaceOverhead
+	}
+	if r.Threads == 0 {
+		r.Threads = baseThreads + threadsPerWorker*defaultInt(s.Workers, 1)
+	}
+
+	available := r.Limit - r.Reserved - r.NonHeap()
+	r.Heap = available * s.HeapPercentage / 100
+	if r.Heap < MinHeap {
+		//shrink the default sizes, configured sizes are kept
+		missing := (MinHeap*100+s.HeapPercentage-1)/s.HeapPercentage - available
+		if s.CodeCache == 0 {
+			missing -= shrink(&r.CodeCache, MinCodeCache, missing)
+		}
+		if s.DirectMemory == 0 {
+			missing -= shrink(&r.DirectMemory, MinDirectMemory, missing)
+		}
+		available = r.Limit - r.Reserved - r.NonHeap()
+		r.Heap = available * s.HeapPercentage / 100
+	}
+	if r.Heap < MinHeap {
+		return r, fmt.Errorf("memory limit of %dM is too small, %dM are needed for non heap memory and reserved memory and the heap would be %dM (minimum %dM): %s",
+			r.Limit, r.Limit-available, r.Heap, MinHeap, r.String())
+	}
+
+	return r, nil
+}
+
+// NonHeap returns the memory used by metaspace, direct memory, code cache and thread stacks
+func (r Result) NonHeap() int {
+	return r.Metaspace + r.DirectMemory + r.CodeCache + r.Stacks()
+}
+
+// Stacks returns the memory of all thread stacks in MB
+func (r Result) Stacks() int {
+	return (r.Threads*r.StackSize + 1023) / 1024
+}
+
+// JavaOpts returns the JVM options which enforce the calculated sizes
+func (r Result) JavaOpts() []string {
+	return []string{
+		fmt.Sprintf("-Xmx%dm", r.Heap),
+		fmt.Sprintf("-Xms%dm", r.Heap),
+		fmt.Sprintf("-XX:MaxMetaspaceSize=%dm", r.Metaspace),
+		fmt.Sprintf("-XX:MaxDirectMemorySize=%dm", r.DirectMemory),
+		fmt.Sprintf("-XX:ReservedCodeCacheSize=%dm", r.CodeCache),
+		fmt.Sprintf("-Xss%dk", r.StackSize),
+	}
+}
+
+// String returns the breakdown of the memory limit
+func (r Result) String() string {
+	parts := []string{
+		fmt.Sprintf("heap %dM", r.Heap),
+		fmt.Sprintf("metaspace %dM", r.Metaspace),
+		fmt.Sprintf("direct memory %dM", r.DirectMemory),
+		fmt.Sprintf("code cache %dM", r.CodeCache),
+		fmt.Sprintf("thread stacks %dM (%d threads x %dK)", r.Stacks(), r.Threads, r.StackSize),
+		fmt.Sprintf("reserved %dM", r.Reserved),
+	}
+	return fmt.Sprintf("%s of %dM", strings.Join(parts, ", "), r.Limit)
+}
+
+// shrink reduces size by up to amount, but not below min, and returns the reduction
+func shrink(size *int, min int, amount int) int {
+	reduction := *size - min
+	if reduction > amount {
+		reduction = amount
+	}
+	if reduction <= 0 {
+		return 0
+	}
+	*size -= reduction
+	return reduction
+}
+
+func defaultInt(value int, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
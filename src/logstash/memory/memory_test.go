@@ -0,0 +1,153 @@
+package memory_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"logstash/memory"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory", func() {
+	var settings memory.Settings
+
+	BeforeEach(func() {
+		settings = memory.Settings{Limit: 2048, HeapPercentage: 90, Workers: 4}
+	})
+
+	Describe("Calculate", func() {
+		It("derives the non heap pools from the defaults and the workers", func() {
+			result, err := memory.Calculate(settings)
+			Expect(err).To(BeNil())
+			Expect(result.Metaspace).To(Equal(125))
+			Expect(result.DirectMemory).To(Equal(memory.DefaultDirectMemory))
+			Expect(result.CodeCache).To(Equal(memory.DefaultCodeCache))
+			Expect(result.Threads).To(Equal(58))
+			Expect(result.Stacks()).To(Equal(58))
+			Expect(result.NonHeap()).To(Equal(311))
+			Expect(result.Heap).To(Equal((2048 - 311) * 90 / 100))
+		})
+
+		It("subtracts the reserved memory", func() {
+			settings.Reserved = 100
+			result, err := memory.Calculate(settings)
+			Expect(err).To(BeNil())
+			Expect(result.Heap).To(Equal((2048 - 100 - 311) * 90 / 100))
+		})
+
+		It("uses the configured sizes", func() {
+			settings.Threads = 100
+			settings.StackSize = 512
+			settings.Metaspace = 200
+			settings.DirectMemory = 128
+			settings.CodeCache = 64
+			result, err := memory.Calculate(settings)
+			Expect(err).To(BeNil())
+			Expect(result.Stacks()).To(Equal(50))
+			Expect(result.NonHeap()).To(Equal(200 + 128 + 64 + 50))
+			Expect(result.JavaOpts()).To(Equal([]string{
+				"-Xmx1445m", "-Xms1445m", "-XX:MaxMetaspaceSize=200m", "-XX:MaxDirectMemorySize=128m", "-XX:ReservedCodeCacheSize=64m", "-Xss512k",
+			}))
+		})
+
+		It("doesn't truncate the heap to full hundreds", func() {
+			settings.Limit = 1000
+			settings.HeapPercentage = 100
+			result, err := memory.Calculate(settings)
+			Expect(err).To(BeNil())
+			Expect(result.Heap).To(Equal(1000 - 311))
+		})
+
+		It("refuses a limit which leaves too little heap", func() {
+			settings.Limit = 400
+			_, err := memory.Calculate(settings)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("memory limit of 400M is too small"))
+		})
+
+		It("shrinks the default direct memory and code cache for small limits", func() {
+			settings.Limit = 512
+			settings.Workers = 1
+			result, err := memory.Calculate(settings)
+			Expect(err).To(BeNil())
+			Expect(result.CodeCache).To(Equal(memory.MinCodeCache))
+			Expect(result.DirectMemory).To(Equal(18))
+			Expect(result.Heap).To(Equal(memory.MinHeap))
+		})
+
+		It("keeps configured sizes for small limits", func() {
+			settings.Limit = 512
+			settings.Workers = 1
+			settings.CodeCache = 64
+			_, err := memory.Calculate(settings)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("refuses an unknown limit", func() {
+			settings.Limit = 0
+			_, err := memory.Calculate(settings)
+			Expect(err).To(MatchError("memory limit is unknown"))
+		})
+
+		It("refuses invalid percentages and negative sizes", func() {
+			settings.HeapPercentage = 120
+			_, err := memory.Calculate(settings)
+			Expect(err).NotTo(BeNil())
+
+			settings.HeapPercentage = 90
+			settings.Metaspace = -1
+			_, err = memory.Calculate(settings)
+			Expect(err).To(MatchError("metaspace must not be negative"))
+		})
+	})
+
+	Describe("String", func() {
+		It("prints the breakdown", func() {
+			result, err := memory.Calculate(settings)
+			Expect(err).To(BeNil())
+			Expect(result.String()).To(Equal("heap 1563M, metaspace 125M, direct memory 64M, code cache 64M, thread stacks 58M (58 threads x 1024K), reserved 0M of 2048M"))
+		})
+	})
+
+	Describe("AvailableCPUs", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "cgroup")
+			Expect(err).To(BeNil())
+			Expect(os.MkdirAll(filepath.Join(dir, "cpu"), 0755)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("uses the quota of cgroup v2", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "cpu.max"), []byte("150000 100000\n"), 0644)).To(Succeed())
+			Expect(memory.AvailableCPUs(dir)).To(Equal(2))
+		})
+
+		It("uses the quota of cgroup v1", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.cfs_quota_us"), []byte("300000\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.cfs_period_us"), []byte("100000\n"), 0644)).To(Succeed())
+			Expect(memory.AvailableCPUs(dir)).To(Equal(3))
+		})
+
+		It("uses the shares without quota", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.cfs_quota_us"), []byte("-1\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.cfs_period_us"), []byte("100000\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "cpu", "cpu.shares"), []byte("512\n"), 0644)).To(Succeed())
+			Expect(memory.AvailableCPUs(dir)).To(Equal(1))
+		})
+
+		It("uses the CPUs of the host without limits", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "cpu.max"), []byte("max 100000\n"), 0644)).To(Succeed())
+			Expect(memory.AvailableCPUs(dir)).To(Equal(runtime.NumCPU()))
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const Mask = "[REDACTED]"
+
+// SecretsFileName is the file in $LS_ROOT with the secrets of the Logstash file which are known at
+// staging only (e.g. the static logstash-credentials.password), the launcher masks them at runtime
+const SecretsFileName = "redact-secrets"
+
+// secrets shorter than this are not masked, they would garble the output
+const minSecretLength = 4
+
+// incomplete lines of a LineWriter are written once they reach this length
+const maxLineLength = 64 * 1024
+
+// credential fields of bound services which contain secrets
+var secretField = regexp.MustCompile(`(?i)(pass|secret|token|key|credential|auth)`)
+
+// Writer masks all known secret values in the data written to the underlying writer
+type Writer struct {
+	w       io.Writer
+	mutex   sync.Mutex
+	secrets []string
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// AddSecret registers a value to be masked
+func (r *Writer) AddSecret(secret string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, s := range r.secrets {
+		if s == secret {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, secret)
+
+	// longer secrets first, a secret may contain another one
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// AddCredentials registers the secrets of service credentials (VCAP_SERVICES)
+func (r *Writer) AddCredentials(credentials map[string]interface{}) {
+	for key, value := range credentials {
+		switch v := value.(type) {
+		case string:
+			if secretField.MatchString(key) {
+				r.AddSecret(v)
+			}
+			if u, err := url.Parse(v); err == nil && u.User != nil {
+				if password, ok := u.User.Password(); ok {
+					r.AddSecret(password)
+				}
+			}
+		case map[string]interface{}:
+			r.AddCredentials(v)
+		}
+	}
+}
+
+// AddSecretsFile registers the secrets of a file written by WriteSecretsFile, one secret per line
+func (r *Writer) AddSecretsFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, secret := range strings.Split(string(data), "\n") {
+		r.AddSecret(secret)
+	}
+	return nil
+}
+
+// WriteSecretsFile writes the secrets one per line, readable by the owner only
+func WriteSecretsFile(file string, secrets []string) error {
+	return ioutil.WriteFile(file, []byte(strings.Join(secrets, "\n")+"\n"), 0600)
+}
+
+// Redact returns the text with all known secrets masked
+func (r *Writer) Redact(text string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, s := range r.secrets {
+		text = strings.Replace(text, s, Mask, -1)
+	}
+	return text
+}
+
+func (r *Writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// LineWriter masks the secrets of a Writer in the output of a child process. The output is written
+// line by line, so a secret split across two writes of the process is masked as well.
+type LineWriter struct {
+	redactor *Writer
+	w        io.Writer
+	mutex    sync.Mutex
+	pending  []byte
+}
+
+// NewLineWriter returns a LineWriter writing to w with the secrets of r
+func (r *Writer) NewLineWriter(w io.Writer) *LineWriter {
+	return &LineWriter{redactor: r, w: w}
+}
+
+func (l *LineWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.pending = append(l.pending, p...)
+	end := bytes.LastIndexByte(l.pending, '\n') + 1
+	if end == 0 && len(l.pending) >= maxLineLength {
+		end = len(l.pending)
+	}
+	if end == 0 {
+		return len(p), nil
+	}
+
+	lines := string(l.pending[:end])
+	l.pending = append([]byte{}, l.pending[end:]...)
+	if _, err := io.WriteString(l.w, l.redactor.Redact(lines)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the incomplete last line
+func (l *LineWriter) Flush() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.pending) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(l.w, l.redactor.Redact(string(l.pending)))
+	l.pending = nil
+	return err
+}
@@ -0,0 +1,109 @@
+package settings
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// knownSetting is a setting of logstash.yml with the Logstash versions supporting it
+type knownSetting struct {
+	since   string
+	removed string
+}
+
+// settings registered by logstash-core (lib/logstash/environment.rb), plugins like x-pack register
+// their own settings below `xpack.`
+var knownSettings = map[string]knownSetting{
+	"node.name":                   {since: "5.0"},
+	"path.config":                 {since: "5.0"},
+	"path.data":                   {since: "5.0"},
+	"path.logs":                   {since: "5.0"},
+	"path.plugins":                {since: "5.0"},
+	"config.string":               {since: "5.0"},
+	"config.test_and_exit":        {since: "5.0"},
+	"config.reload.automatic":     {since: "5.0"},
+	"config.reload.interval":      {since: "5.0"},
+	"config.debug":                {since: "5.0"},
+	"config.support_escapes":      {since: "6.1"},
+	"metric.collect":              {since: "5.0"},
+	"pipeline.id":                 {since: "5.0"},
+	"pipeline.workers":            {since: "5.0"},
+	"pipeline.output.workers":     {since: "5.0", removed: "6.0"},
+	"pipeline.batch.size":         {since: "5.0"},
+	"pipeline.batch.delay":        {since: "5.0"},
+	"pipeline.unsafe_shutdown":    {since: "5.0"},
+	"pipeline.java_execution":     {since: "6.3"},
+	"log.level":                   {since: "5.0"},
+	"log.format":                  {since: "5.0"},
+	"http.host":                   {since: "5.0"},
+	"http.port":                   {since: "5.0"},
+	"http.environment":            {since: "5.0"},
+	"slowlog.threshold.warn":      {since: "5.0"},
+	"slowlog.threshold.info":      {since: "5.0"},
+	"slowlog.threshold.debug":     {since: "5.0"},
+	"slowlog.threshold.trace":     {since: "5.0"},
+	"queue.type":                  {since: "5.1"},
+	"path.queue":                  {since: "5.1"},
+	"queue.page_capacity":         {since: "5.1"},
+	"queue.max_events":            {since: "5.1"},
+	"queue.max_bytes":             {since: "5.1"},
+	"queue.checkpoint.acks":       {since: "5.1"},
+	"queue.checkpoint.writes":     {since: "5.1"},
+	"queue.checkpoint.interval":   {since: "5.1"},
+	"queue.checkpoint.retry":      {since: "6.3"},
+	"queue.drain":                 {since: "5.5"},
+	"dead_letter_queue.enable":    {since: "5.5"},
+	"dead_letter_queue.max_bytes": {since: "5.5"},
+	"path.dead_letter_queue":      {since: "5.5"},
+	"modules":                     {since: "6.0"},
+	"cloud.id":                    {since: "6.0"},
+	"cloud.auth":                  {since: "6.0"},
+}
+
+// IsKnown reports whether the setting is registered by logstash-core or belongs to a plugin
+// (`xpack.*`). Settings of newer Logstash versions are unknown until they are added above.
+func IsKnown(key string) bool {
+	if strings.HasPrefix(key, "xpack.") {
+		return true
+	}
+	_, ok := knownSettings[key]
+	return ok
+}
+
+// Validate checks that a known setting is supported by the Logstash version, unknown settings and
+// settings of plugins (`xpack.*`) are left to Logstash
+func Validate(key string, version string) error {
+	known, ok := knownSettings[key]
+	if !ok {
+		return nil
+	}
+	if known.since != "" && compareVersions(version, known.since) < 0 {
+		return fmt.Errorf("setting '%s' requires Logstash %s or later", key, known.since)
+	}
+	if known.removed != "" && compareVersions(version, known.removed) >= 0 {
+		return fmt.Errorf("setting '%s' was removed in Logstash %s", key, known.removed)
+	}
+	return nil
+}
+
+// compareVersions compares the numeric parts of two versions, e.g. 6.1.3 and 6.1. Versions which
+// can't be parsed compare as equal, the check is left to Logstash.
+func compareVersions(a string, b string) int {
+	partsA := strings.Split(a, ".")
+	partsB := strings.Split(b, ".")
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		numberA, errA := strconv.Atoi(partsA[i])
+		numberB, errB := strconv.Atoi(partsB[i])
+		if errA != nil || errB != nil {
+			return 0
+		}
+		if numberA != numberB {
+			if numberA < numberB {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileName of the Logstash settings in $LOGSTASH_HOME/config, the buildpack settings are
+// stored with the same name in $DEPS_DIR/<idx>
+const FileName = "logstash.yml"
+
+// OriginalFileName is the copy of the logstash.yml shipped with Logstash, it's the base of
+// the rendering if the app has no logstash.yml
+const OriginalFileName = "logstash.yml.orig"
+
+// Settings of logstash.yml with flat keys, e.g. `queue.type`. Logstash flattens nested
+// settings itself, so `queue: {type: persisted}` and `queue.type: persisted` are the same.
+type Settings map[string]interface{}
+
+// Parse reads logstash.yml and flattens nested settings
+func Parse(data []byte) (settings Settings, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("Yaml parsing error: %s", r))
+		}
+	}()
+
+	raw := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	settings = Settings{}
+	flatten("", raw, settings)
+	return settings, nil
+}
+
+// Flatten returns the settings of a parsed yaml map (e.g. the `settings:` section of the Logstash file)
+func Flatten(values map[string]interface{}) Settings {
+	settings := Settings{}
+	for key, value := range values {
+		flattenValue(key, value, settings)
+	}
+	return settings
+}
+
+func flattenValue(key string, value interface{}, settings Settings) {
+	switch nested := value.(type) {
+	case map[interface{}]interface{}:
+		for nestedKey, nestedValue := range nested {
+			flattenValue(fmt.Sprintf("%s.%v", key, nestedKey), nestedValue, settings)
+		}
+	case map[string]interface{}:
+		for nestedKey, nestedValue := range nested {
+			flattenValue(key+"."+nestedKey, nestedValue, settings)
+		}
+	case yaml.MapSlice:
+		flatten(key+".", nested, settings)
+	case nil:
+		//`key:` without value, e.g. a section with all settings commented out
+	default:
+		settings[key] = value
+	}
+}
+
+func flatten(prefix string, values yaml.MapSlice, settings Settings) {
+	for _, item := range values {
+		flattenValue(prefix+fmt.Sprintf("%v", item.Key), item.Value, settings)
+	}
+}
+
+// Keys returns the sorted keys
+func (s Settings) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Int returns a positive integer setting, e.g. `pipeline.workers`
+func (s Settings) Int(key string) (int, bool) {
+	value, err := strconv.Atoi(strings.TrimSpace(fmt.Sprintf("%v", s[key])))
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+// Marshal returns the settings as logstash.yml with sorted flat keys
+func (s Settings) Marshal() ([]byte, error) {
+	slice := yaml.MapSlice{}
+	for _, key := range s.Keys() {
+		slice = append(slice, yaml.MapItem{Key: key, Value: s[key]})
+	}
+	if len(slice) == 0 {
+		return []byte{}, nil
+	}
+	return yaml.Marshal(slice)
+}
+
+// Merge returns base with the overrides applied and the keys of base which got a different value
+func Merge(base, overrides Settings) (Settings, []string) {
+	merged := Settings{}
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	overridden := []string{}
+	for _, key := range overrides.Keys() {
+		if value, ok := base[key]; ok && fmt.Sprintf("%v", value) != fmt.Sprintf("%v", overrides[key]) {
+			overridden = append(overridden, key)
+		}
+		merged[key] = overrides[key]
+	}
+	return merged, overridden
+}
+
+// ReadFile parses a logstash.yml, missing files are treated as empty
+func ReadFile(file string) (Settings, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return Settings{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// WriteFile writes the settings as logstash.yml
+func WriteFile(file string, settings Settings) error {
+	data, err := settings.Marshal()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// KeepOriginal copies the logstash.yml shipped with Logstash to logstash.yml.orig once
+func KeepOriginal(logstashHome string) error {
+	configDir := filepath.Join(logstashHome, "config")
+	originalFile := filepath.Join(configDir, OriginalFileName)
+
+	if _, err := os.Stat(originalFile); err == nil {
+		return nil
+	}
+	original, err := ioutil.ReadFile(filepath.Join(configDir, FileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(originalFile, original, 0644)
+}
+
+// ResolvePaths makes the relative `path.*` settings absolute, relative to dir (the app directory)
+func (s Settings) ResolvePaths(dir string) {
+	for key, value := range s {
+		path, ok := value.(string)
+		if strings.HasPrefix(key, "path.") && ok && path != "" && !filepath.IsAbs(path) {
+			s[key] = filepath.Join(dir, path)
+		}
+	}
+}
+
+var byteSizePattern = regexp.MustCompile(`^(\d+)\s*(b|kb|mb|gb|tb|pb)$`)
+
+var byteSizeUnits = map[string]int64{
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+	"tb": 1 << 40,
+	"pb": 1 << 50,
+}
+
+// ParseByteSize parses a size in the format of Logstash, e.g. `1024mb` or `4gb`
+func ParseByteSize(size string) (int64, error) {
+	match := byteSizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(size)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size '%s' (e.g. 1024mb or 4gb)", size)
+	}
+	value, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %s", size, err.Error())
+	}
+	return value * byteSizeUnits[match[2]], nil
+}
@@ -0,0 +1,126 @@
+package settings_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"logstash/settings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Settings", func() {
+	Describe("Parse", func() {
+		It("flattens nested settings", func() {
+			s, err := settings.Parse([]byte("queue:\n  type: persisted\n  checkpoint:\n    writes: 1\npipeline.workers: 2\nxpack.management.pipeline.id: [main, apache]\n"))
+			Expect(err).To(BeNil())
+			Expect(s).To(HaveLen(4))
+			Expect(s["queue.type"]).To(Equal("persisted"))
+			Expect(s["queue.checkpoint.writes"]).To(Equal(1))
+			Expect(s["pipeline.workers"]).To(Equal(2))
+			Expect(s["xpack.management.pipeline.id"]).To(Equal([]interface{}{"main", "apache"}))
+		})
+
+		It("returns no settings for a commented out file", func() {
+			s, err := settings.Parse([]byte("# path.data:\n"))
+			Expect(err).To(BeNil())
+			Expect(s).To(BeEmpty())
+		})
+
+		It("fails on invalid yaml", func() {
+			_, err := settings.Parse([]byte("queue: [\n"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("Flatten", func() {
+		It("flattens the maps of the Logstash file", func() {
+			s := settings.Flatten(map[string]interface{}{
+				"pipeline":  map[interface{}]interface{}{"workers": 2, "batch": map[interface{}]interface{}{"size": 250}},
+				"log.level": "debug",
+				"modules":   []interface{}{"netflow"},
+			})
+			Expect(s).To(Equal(settings.Settings{"pipeline.workers": 2, "pipeline.batch.size": 250, "log.level": "debug", "modules": []interface{}{"netflow"}}))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts the settings of the Logstash version", func() {
+			Expect(settings.Validate("pipeline.workers", "6.1.3")).To(Succeed())
+			Expect(settings.Validate("config.support_escapes", "6.1.3")).To(Succeed())
+			Expect(settings.Validate("xpack.monitoring.enabled", "6.1.3")).To(Succeed())
+		})
+
+		It("leaves unknown settings to Logstash", func() {
+			Expect(settings.Validate("pipeline.worker", "6.1.3")).To(Succeed())
+			Expect(settings.IsKnown("pipeline.worker")).To(BeFalse())
+			Expect(settings.IsKnown("pipeline.workers")).To(BeTrue())
+			Expect(settings.IsKnown("xpack.monitoring.enabled")).To(BeTrue())
+		})
+
+		It("refuses settings of other versions", func() {
+			Expect(settings.Validate("pipeline.java_execution", "6.1.3")).To(MatchError("setting 'pipeline.java_execution' requires Logstash 6.3 or later"))
+			Expect(settings.Validate("pipeline.output.workers", "6.1.3")).To(MatchError("setting 'pipeline.output.workers' was removed in Logstash 6.0"))
+			Expect(settings.Validate("pipeline.output.workers", "5.6.8")).To(Succeed())
+		})
+	})
+
+	Describe("Marshal", func() {
+		It("writes sorted flat keys", func() {
+			data, err := settings.Settings{"queue.type": "persisted", "pipeline.workers": 2, "xpack.management.pipeline.id": []interface{}{"main"}}.Marshal()
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(Equal("pipeline.workers: 2\nqueue.type: persisted\nxpack.management.pipeline.id:\n- main\n"))
+		})
+	})
+
+	Describe("Merge", func() {
+		It("applies the overrides and reports changed values", func() {
+			merged, overridden := settings.Merge(
+				settings.Settings{"queue.type": "memory", "pipeline.workers": 2, "queue.max_bytes": "1gb"},
+				settings.Settings{"queue.type": "persisted", "queue.max_bytes": "1gb", "path.queue": "/queue"})
+			Expect(merged).To(Equal(settings.Settings{"queue.type": "persisted", "pipeline.workers": 2, "queue.max_bytes": "1gb", "path.queue": "/queue"}))
+			Expect(overridden).To(Equal([]string{"queue.type"}))
+		})
+	})
+
+	Describe("ResolvePaths", func() {
+		It("makes relative paths absolute", func() {
+			s := settings.Settings{"path.queue": "queue", "path.data": "/data", "queue.type": "persisted"}
+			s.ResolvePaths("/home/vcap/app")
+			Expect(s).To(Equal(settings.Settings{"path.queue": "/home/vcap/app/queue", "path.data": "/data", "queue.type": "persisted"}))
+		})
+	})
+
+	Describe("KeepOriginal", func() {
+		It("copies logstash.yml once", func() {
+			home, err := ioutil.TempDir("", "settings")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(home)
+			Expect(os.MkdirAll(filepath.Join(home, "config"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(home, "config", "logstash.yml"), []byte("# original\n"), 0644)).To(Succeed())
+
+			Expect(settings.KeepOriginal(home)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(home, "config", "logstash.yml"), []byte("queue.type: persisted\n"), 0644)).To(Succeed())
+			Expect(settings.KeepOriginal(home)).To(Succeed())
+
+			data, err := ioutil.ReadFile(filepath.Join(home, "config", "logstash.yml.orig"))
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(Equal("# original\n"))
+		})
+	})
+
+	Describe("ParseByteSize", func() {
+		It("parses the units of Logstash", func() {
+			for size, bytes := range map[string]int64{"100b": 100, "64kb": 64 << 10, "1024mb": 1 << 30, "4GB": 4 << 30, "1tb": 1 << 40} {
+				Expect(settings.ParseByteSize(size)).To(Equal(bytes))
+			}
+		})
+
+		It("refuses sizes without unit", func() {
+			_, err := settings.ParseByteSize("1024")
+			Expect(err).To(MatchError("invalid size '1024' (e.g. 1024mb or 4gb)"))
+		})
+	})
+})
@@ -2,6 +2,7 @@ package main
 
 import (
 	_ "logstash/hooks"
+	"logstash/redact"
 	"logstash/supply"
 	"os"
 	"time"
@@ -10,7 +11,8 @@ import (
 )
 
 func main() {
-	logger := libbuildpack.NewLogger(os.Stdout)
+	redactor := redact.NewWriter(os.Stdout)
+	logger := libbuildpack.NewLogger(redactor)
 
 	buildpackDir, err := libbuildpack.GetBuildpackDir()
 	if err != nil {
@@ -42,6 +44,7 @@ func main() {
 	gs := supply.Supplier{
 		Stager:       stager,
 		Log:          logger,
+		Redactor:     redactor,
 		Manifest:     manifest,
 		BuildpackDir: buildpackDir,
 	}
@@ -0,0 +1,42 @@
+package supply
+
+import (
+	"errors"
+)
+
+// modes of `curator.mode`, they decide which instances of the web (and worker) process schedule Curator
+const (
+	curatorModeDaemon = "daemon" // every instance
+	curatorModeLeader = "leader" // only the instance with CF_INSTANCE_INDEX 0
+	curatorModeTask   = "task"   // none, Curator runs as task (bin/curator-task) or in the curator process
+)
+
+// InstallCuratorMode validates `curator.mode`, the launcher reads it from LS_CURATOR_MODE
+func (gs *Supplier) InstallCuratorMode() error {
+	curator := &gs.LogstashConfig.Curator
+	if !curator.Install {
+		//the mode is irrelevant without Curator, the launcher defaults the empty LS_CURATOR_MODE
+		curator.Mode = ""
+		return nil
+	}
+	if curator.Mode == "" {
+		curator.Mode = curatorModeDaemon
+	}
+
+	switch curator.Mode {
+	case curatorModeDaemon, curatorModeLeader, curatorModeTask:
+	default:
+		gs.Log.Error("curator.mode must be '%s', '%s' or '%s': '%s'", curatorModeDaemon, curatorModeLeader, curatorModeTask, curator.Mode)
+		return errors.New("invalid curator mode")
+	}
+
+	switch curator.Mode {
+	case curatorModeDaemon:
+		gs.Log.Info("Curator runs in every instance (curator.mode: daemon), use curator.mode 'leader' or 'task' with more than one instance")
+	case curatorModeLeader:
+		gs.Log.Info("Curator runs in the first instance (CF_INSTANCE_INDEX 0) only")
+	case curatorModeTask:
+		gs.Log.Info("Curator is not scheduled by the Logstash instances, run 'cf run-task <app> bin/curator-task' or scale the curator process (cf scale <app> --process curator -i 1)")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package supply_test
+
+import (
+	"bytes"
+
+	conf "logstash/config"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Curator", func() {
+	var (
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		supplier = &supply.Supplier{Log: libbuildpack.NewLogger(buffer)}
+	})
+
+	Describe("InstallCuratorMode", func() {
+		It("defaults the mode", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Curator: conf.Curator{Install: true}}
+
+			Expect(supplier.InstallCuratorMode()).To(Succeed())
+			Expect(supplier.LogstashConfig.Curator.Mode).To(Equal("daemon"))
+		})
+
+		It("fails for an invalid mode", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Curator: conf.Curator{Install: true, Mode: "cron"}}
+
+			Expect(supplier.InstallCuratorMode()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("curator.mode must be"))
+		})
+
+		It("ignores the mode without Curator", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Curator: conf.Curator{Mode: "cron"}}
+
+			Expect(supplier.InstallCuratorMode()).To(Succeed())
+			Expect(supplier.LogstashConfig.Curator.Mode).To(BeEmpty())
+		})
+	})
+})
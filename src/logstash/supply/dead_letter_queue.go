@@ -0,0 +1,98 @@
+package supply
+
+import (
+	"errors"
+	"logstash/settings"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// template reading the dead letter queue (defaults/templates)
+const deadLetterQueueTemplate = "cf-input-dead-letter-queue"
+
+const defaultDeadLetterQueueTarget = "index"
+const defaultDeadLetterQueueIndex = "dead-letter-%{+YYYY.MM.dd}"
+const defaultDeadLetterQueuePipelineId = "main"
+
+// InstallDeadLetterQueue validates the `dead-letter-queue:` section and adds it to the buildpack
+// settings of logstash.yml
+func (gs *Supplier) InstallDeadLetterQueue() error {
+	dlq := &gs.LogstashConfig.DeadLetterQueue
+	if !dlq.Enabled {
+		return nil
+	}
+
+	if dlq.Target == "" {
+		dlq.Target = defaultDeadLetterQueueTarget
+	}
+	if dlq.Index == "" {
+		dlq.Index = defaultDeadLetterQueueIndex
+	}
+	if dlq.PipelineId == "" {
+		dlq.PipelineId = defaultDeadLetterQueuePipelineId
+	}
+	if strings.ContainsAny(dlq.Index, "\" \t\\") || strings.Contains(dlq.Index, "{{") {
+		gs.Log.Error("dead-letter-queue.index must not contain whitespace, quotes, backslashes or '{{': '%s'", dlq.Index)
+		return errors.New("invalid dead letter queue index")
+	}
+	if strings.ContainsAny(dlq.PipelineId, "\" \t\\") || strings.Contains(dlq.PipelineId, "{{") {
+		gs.Log.Error("dead-letter-queue.pipeline-id must not contain whitespace, quotes, backslashes or '{{': '%s'", dlq.PipelineId)
+		return errors.New("invalid dead letter queue pipeline id")
+	}
+	if dlq.Target != "index" && dlq.Target != "stdout" {
+		gs.Log.Error("dead-letter-queue.target must be 'index' or 'stdout': '%s'", dlq.Target)
+		return errors.New("invalid dead letter queue target")
+	}
+	if dlq.MaxBytes != "" {
+		if _, err := settings.ParseByteSize(dlq.MaxBytes); err != nil {
+			gs.Log.Error("dead-letter-queue.max-bytes: %s", err.Error())
+			return err
+		}
+		gs.LogstashSettings["dead_letter_queue.max_bytes"] = dlq.MaxBytes
+	}
+	if dlq.Path != "" {
+		gs.LogstashSettings["path.dead_letter_queue"] = dlq.Path
+	}
+	gs.LogstashSettings["dead_letter_queue.enable"] = true
+
+	gs.Log.Info("Dead letter queue enabled, select the template %s to process its events", deadLetterQueueTemplate)
+	return nil
+}
+
+// PrepareDeadLetterQueueTemplates sets the variables of the staging pass which route the events
+// of the dead letter queue, if the template cf-input-dead-letter-queue is installed
+func (gs *Supplier) PrepareDeadLetterQueueTemplates() error {
+	os.Setenv("DEAD_LETTER_QUEUE_TARGET", "")
+	os.Setenv("DEAD_LETTER_QUEUE_INDEX", "")
+	os.Setenv("DEAD_LETTER_QUEUE_PIPELINE_ID", "")
+
+	for _, ti := range gs.TemplatesToInstall {
+		if ti.Name != deadLetterQueueTemplate {
+			continue
+		}
+		dlq := gs.LogstashConfig.DeadLetterQueue
+		if !dlq.Enabled {
+			gs.Log.Error("Template %s requires dead-letter-queue.enabled in the Logstash file", deadLetterQueueTemplate)
+			return errors.New("dead letter queue not enabled")
+		}
+		os.Setenv("DEAD_LETTER_QUEUE_TARGET", dlq.Target)
+		os.Setenv("DEAD_LETTER_QUEUE_INDEX", dlq.Index)
+		os.Setenv("DEAD_LETTER_QUEUE_PIPELINE_ID", dlq.PipelineId)
+		os.Setenv("LS_DEAD_LETTER_QUEUE_PATH", filepath.Join(gs.Stager.DepDir(), "dead_letter_queue")) //dummy path: used by template processing for the Logstash config check
+	}
+	return nil
+}
+
+// exportDeadLetterQueuePath exports the directory of the dead letter queue at runtime (LS_DEAD_LETTER_QUEUE_PATH)
+func (gs *Supplier) exportDeadLetterQueuePath(profileD *ProfileD) *ProfileD {
+	path := gs.LogstashConfig.DeadLetterQueue.Path
+	switch {
+	case path == "":
+		return profileD.ExportDepPath("LS_DEAD_LETTER_QUEUE_PATH", filepath.Join(gs.Logstash.RuntimeLocation, "data", "dead_letter_queue"))
+	case filepath.IsAbs(path):
+		return profileD.Export("LS_DEAD_LETTER_QUEUE_PATH", path)
+	default:
+		return profileD.ExportExpanded("LS_DEAD_LETTER_QUEUE_PATH", "$HOME/"+path)
+	}
+}
@@ -0,0 +1,166 @@
+package supply_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	conf "logstash/config"
+	"logstash/settings"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// renderStagingTemplate renders the staging pass (delimiters << >>) of a template of
+// defaults/templates like gte does, the runtime pass ({{ }}) is left untouched
+func renderStagingTemplate(name string, env map[string]string) string {
+	data, err := ioutil.ReadFile(filepath.Join("..", "..", "..", "defaults", "templates", name+".conf"))
+	Expect(err).To(BeNil())
+
+	t, err := template.New(name).Delims("<<", ">>").Parse(string(data))
+	Expect(err).To(BeNil())
+
+	out := new(bytes.Buffer)
+	Expect(t.Execute(out, map[string]interface{}{"Env": env})).To(Succeed())
+	return out.String()
+}
+
+var _ = Describe("Dead letter queue", func() {
+	var (
+		depsDir  string
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		var err error
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+
+		buffer = new(bytes.Buffer)
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager:           libbuildpack.NewStager([]string{"", "", depsDir, "0"}, logger, nil),
+			Log:              logger,
+			LogstashSettings: settings.Settings{},
+		}
+	})
+
+	AfterEach(func() {
+		for _, name := range []string{"DEAD_LETTER_QUEUE_TARGET", "DEAD_LETTER_QUEUE_INDEX", "DEAD_LETTER_QUEUE_PIPELINE_ID", "LS_DEAD_LETTER_QUEUE_PATH"} {
+			os.Unsetenv(name)
+		}
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	Describe("InstallDeadLetterQueue", func() {
+		It("does nothing if the dead letter queue is disabled", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{MaxBytes: "invalid"}}
+
+			Expect(supplier.InstallDeadLetterQueue()).To(Succeed())
+			Expect(supplier.LogstashSettings).To(BeEmpty())
+		})
+
+		It("enables the dead letter queue with the defaults", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{Enabled: true}}
+
+			Expect(supplier.InstallDeadLetterQueue()).To(Succeed())
+			Expect(supplier.LogstashSettings).To(Equal(settings.Settings{"dead_letter_queue.enable": true}))
+			Expect(supplier.LogstashConfig.DeadLetterQueue).To(Equal(conf.DeadLetterQueue{
+				Enabled:    true,
+				Target:     "index",
+				Index:      "dead-letter-%{+YYYY.MM.dd}",
+				PipelineId: "main",
+			}))
+		})
+
+		It("adds the capacity and the path to the settings", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{Enabled: true, MaxBytes: "256mb", Path: "dlq"}}
+
+			Expect(supplier.InstallDeadLetterQueue()).To(Succeed())
+			Expect(supplier.LogstashSettings).To(Equal(settings.Settings{
+				"dead_letter_queue.enable":    true,
+				"dead_letter_queue.max_bytes": "256mb",
+				"path.dead_letter_queue":      "dlq",
+			}))
+		})
+
+		It("rejects invalid settings", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{Enabled: true, Index: "dead letter"}}
+			Expect(supplier.InstallDeadLetterQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("dead-letter-queue.index must not contain"))
+
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{Enabled: true, PipelineId: `main" }`}}
+			Expect(supplier.InstallDeadLetterQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("dead-letter-queue.pipeline-id must not contain"))
+
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{Enabled: true, Target: "file"}}
+			Expect(supplier.InstallDeadLetterQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("dead-letter-queue.target must be 'index' or 'stdout': 'file'"))
+
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{Enabled: true, MaxBytes: "lots"}}
+			Expect(supplier.InstallDeadLetterQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("dead-letter-queue.max-bytes:"))
+
+			Expect(supplier.LogstashSettings).To(BeEmpty())
+		})
+	})
+
+	Describe("PrepareDeadLetterQueueTemplates", func() {
+		BeforeEach(func() {
+			supplier.TemplatesToInstall = []conf.Template{{Name: "cf-input-dead-letter-queue"}}
+		})
+
+		It("sets the variables of the staging pass", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{DeadLetterQueue: conf.DeadLetterQueue{Enabled: true, PipelineId: "beats"}}
+			Expect(supplier.InstallDeadLetterQueue()).To(Succeed())
+
+			Expect(supplier.PrepareDeadLetterQueueTemplates()).To(Succeed())
+			Expect(os.Getenv("DEAD_LETTER_QUEUE_TARGET")).To(Equal("index"))
+			Expect(os.Getenv("DEAD_LETTER_QUEUE_INDEX")).To(Equal("dead-letter-%{+YYYY.MM.dd}"))
+			Expect(os.Getenv("DEAD_LETTER_QUEUE_PIPELINE_ID")).To(Equal("beats"))
+			Expect(os.Getenv("LS_DEAD_LETTER_QUEUE_PATH")).To(Equal(filepath.Join(depsDir, "0", "dead_letter_queue")))
+		})
+
+		It("resets the variables without the template", func() {
+			os.Setenv("DEAD_LETTER_QUEUE_TARGET", "stdout")
+			supplier.TemplatesToInstall = []conf.Template{}
+
+			Expect(supplier.PrepareDeadLetterQueueTemplates()).To(Succeed())
+			Expect(os.Getenv("DEAD_LETTER_QUEUE_TARGET")).To(BeEmpty())
+		})
+
+		It("fails if the dead letter queue is not enabled", func() {
+			Expect(supplier.PrepareDeadLetterQueueTemplates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Template cf-input-dead-letter-queue requires dead-letter-queue.enabled"))
+		})
+	})
+
+	Describe("cf-input-dead-letter-queue template", func() {
+		It("reads the dead letter queue of the configured pipeline", func() {
+			rendered := renderStagingTemplate("cf-input-dead-letter-queue", map[string]string{
+				"DEAD_LETTER_QUEUE_TARGET":      "index",
+				"DEAD_LETTER_QUEUE_PIPELINE_ID": "beats",
+			})
+
+			Expect(rendered).To(ContainSubstring(`pipeline_id => "beats"`))
+			Expect(rendered).To(ContainSubstring(`path => "{{ .Env.LS_DEAD_LETTER_QUEUE_PATH }}"`))
+			Expect(rendered).NotTo(ContainSubstring("stdout"))
+		})
+
+		It("writes the events to standard output with target stdout", func() {
+			rendered := renderStagingTemplate("cf-input-dead-letter-queue", map[string]string{
+				"DEAD_LETTER_QUEUE_TARGET":      "stdout",
+				"DEAD_LETTER_QUEUE_PIPELINE_ID": "main",
+			})
+
+			Expect(rendered).To(ContainSubstring(`pipeline_id => "main"`))
+			Expect(rendered).To(ContainSubstring("stdout { codec => rubydebug }"))
+		})
+	})
+})
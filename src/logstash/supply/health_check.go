@@ -0,0 +1,67 @@
+package supply
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const defaultHealthCheckPath = "/_health"
+const defaultHealthCheckInternalPort = 8081
+const defaultHealthCheckAPIPort = 9600
+const defaultHealthCheckStallTimeout = 60
+
+// InstallHealthCheck validates the `health-check:` section. If it's enabled the launcher serves
+// the health endpoint on $PORT and the inputs of Logstash listen on the internal port.
+func (gs *Supplier) InstallHealthCheck() error {
+	healthCheck := &gs.LogstashConfig.HealthCheck
+	if !healthCheck.Enabled {
+		return nil
+	}
+
+	if healthCheck.Path == "" {
+		healthCheck.Path = defaultHealthCheckPath
+	}
+	if healthCheck.InternalPort == 0 {
+		healthCheck.InternalPort = defaultHealthCheckInternalPort
+	}
+	if healthCheck.APIPort == 0 {
+		healthCheck.APIPort = defaultHealthCheckAPIPort
+	}
+	if healthCheck.StallTimeout == 0 {
+		healthCheck.StallTimeout = defaultHealthCheckStallTimeout
+	}
+
+	if !strings.HasPrefix(healthCheck.Path, "/") || strings.ContainsAny(healthCheck.Path, " ?#\t\r\n") {
+		gs.Log.Error("health-check.path must start with '/' and must not contain whitespace, '?' or '#': '%s'", healthCheck.Path)
+		return errors.New("invalid health check path")
+	}
+	for name, port := range map[string]int{"internal-port": healthCheck.InternalPort, "api-port": healthCheck.APIPort} {
+		if port < 1 || port > 65535 {
+			gs.Log.Error("health-check.%s is not a valid port: %d", name, port)
+			return fmt.Errorf("invalid health check %s", name)
+		}
+	}
+	if healthCheck.InternalPort == healthCheck.APIPort {
+		gs.Log.Error("health-check.internal-port and health-check.api-port must differ")
+		return errors.New("invalid health check ports")
+	}
+	if healthCheck.StallTimeout < 0 {
+		gs.Log.Error("health-check.stall-timeout must not be negative")
+		return errors.New("invalid health check stall timeout")
+	}
+
+	//the health check reads the node stats from this port, Logstash defaults to the first free port of 9600-9700
+	gs.LogstashSettings["http.port"] = healthCheck.APIPort
+
+	gs.Log.Info("Health check endpoint %s, Logstash inputs listen on port %d", healthCheck.Path, healthCheck.InternalPort)
+
+	profileD := NewProfileD().
+		Export("LS_BP_HEALTH_CHECK", "enabled").
+		Export("LS_BP_HEALTH_CHECK_PATH", healthCheck.Path).
+		ExportInt("LS_BP_HEALTH_CHECK_INTERNAL_PORT", healthCheck.InternalPort).
+		ExportInt("LS_BP_HEALTH_CHECK_API_PORT", healthCheck.APIPort).
+		ExportInt("LS_BP_HEALTH_CHECK_STALL_TIMEOUT", healthCheck.StallTimeout)
+
+	return gs.WriteDependencyProfileD("health-check", profileD)
+}
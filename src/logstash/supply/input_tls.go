@@ -0,0 +1,185 @@
+package supply
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"logstash/certificates"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultInputTLSCertificateField = "certificate"
+const defaultInputTLSKeyField = "private_key"
+
+// client-auth values of the Logstash file mapped to "ssl_verify_mode" of the http input
+var inputTLSVerifyModes = map[string]string{
+	"none":     "none",
+	"optional": "peer",
+	"required": "force_peer",
+}
+
+// protocols of the Logstash file mapped to "tls_min_version"/"tls_max_version" of the http input
+var inputTLSProtocols = map[string]string{
+	"TLSv1":   "1",
+	"TLSv1.1": "1.1",
+	"TLSv1.2": "1.2",
+}
+
+var cipherSuiteFormat = regexp.MustCompile("^[A-Za-z0-9_]+$")
+
+// InstallInputTLS validates the TLS settings of the input templates and installs certificate and key
+func (gs *Supplier) InstallInputTLS() error {
+	inputTLS := gs.LogstashConfig.InputTLS
+
+	os.Setenv("INPUT_TLS", "false")
+	if !inputTLS.Enabled() {
+		return nil
+	}
+
+	//validate settings
+	clientAuth := strings.ToLower(strings.TrimSpace(inputTLS.ClientAuth))
+	if clientAuth == "" {
+		clientAuth = "none"
+	}
+	verifyMode, ok := inputTLSVerifyModes[clientAuth]
+	if !ok {
+		gs.Log.Error("Invalid value '%s' for 'input-tls.client-auth' in Logstash file (allowed: none, optional, required)", inputTLS.ClientAuth)
+		return errors.New("invalid input-tls client-auth")
+	}
+	if clientAuth != "none" && len(gs.LogstashConfig.Certificates) == 0 {
+		gs.Log.Error("'input-tls.client-auth: %s' requires the CA certificates of the clients in 'certificates' of Logstash file", clientAuth)
+		return errors.New("no certificates to verify clients")
+	}
+
+	minVersion, maxVersion := "", ""
+	for _, p := range inputTLS.Protocols {
+		version, ok := inputTLSProtocols[p]
+		if !ok {
+			gs.Log.Error("Invalid protocol '%s' in 'input-tls.protocols' of Logstash file (allowed: TLSv1, TLSv1.1, TLSv1.2)", p)
+			return errors.New("invalid input-tls protocol")
+		}
+		if minVersion == "" || version < minVersion {
+			minVersion = version
+		}
+		if maxVersion == "" || version > maxVersion {
+			maxVersion = version
+		}
+	}
+
+	cipherSuites := []string{}
+	for _, c := range inputTLS.CipherSuites {
+		if !cipherSuiteFormat.MatchString(c) {
+			gs.Log.Error("Invalid cipher suite '%s' in 'input-tls.cipher-suites' of Logstash file", c)
+			return errors.New("invalid input-tls cipher suite")
+		}
+		cipherSuites = append(cipherSuites, fmt.Sprintf("\"%s\"", c))
+	}
+
+	//read and check certificate and key
+	certificateField := inputTLS.CertificateField
+	if certificateField == "" {
+		certificateField = defaultInputTLSCertificateField
+	}
+	keyField := inputTLS.KeyField
+	if keyField == "" {
+		keyField = defaultInputTLSKeyField
+	}
+	certPEM, keyPEM, err := gs.readCertificateAndKey(inputTLS.Certificate, inputTLS.Key, inputTLS.ServiceInstanceName, certificateField, keyField)
+	if err != nil {
+		gs.Log.Error("Unable to read certificate and key of 'input-tls': %s", err.Error())
+		return err
+	}
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		gs.Log.Error("Invalid certificate/key pair in 'input-tls': %s", err.Error())
+		return err
+	}
+	certs, err := certificates.Parse(certPEM)
+	if err != nil {
+		return err
+	}
+	if err := gs.CheckCertificates("input-tls", certs); err != nil {
+		return err
+	}
+
+	//install certificate and key (the inputs require the key in PKCS8 format)
+	destDir := filepath.Join(gs.Stager.DepDir(), "tls")
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	certFile := filepath.Join(destDir, "input.crt")
+	keyFile := filepath.Join(destDir, "input.key")
+	if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(keyFile+".orig", keyPEM, 0600); err != nil {
+		return err
+	}
+	defer os.Remove(keyFile + ".orig")
+
+	if out, err := exec.Command("openssl", "pkcs8", "-topk8", "-nocrypt", "-in", keyFile+".orig", "-out", keyFile).CombinedOutput(); err != nil {
+		gs.Log.Error("%s", string(out))
+		gs.Log.Error("Error converting the key of 'input-tls' to PKCS8: %s", err.Error())
+		return err
+	}
+	if err := os.Chmod(keyFile, 0600); err != nil {
+		return err
+	}
+
+	//used by the template processing in InstallTemplates
+	os.Setenv("INPUT_TLS", "true")
+	os.Setenv("INPUT_TLS_VERIFY_MODE", verifyMode)
+	os.Setenv("INPUT_TLS_CLIENT_AUTH", clientAuth)
+	os.Setenv("INPUT_TLS_MIN_VERSION", minVersion)
+	os.Setenv("INPUT_TLS_MAX_VERSION", maxVersion)
+	os.Setenv("INPUT_TLS_CIPHER_SUITES", "")
+	if len(cipherSuites) > 0 {
+		os.Setenv("INPUT_TLS_CIPHER_SUITES", "["+strings.Join(cipherSuites, ", ")+"]")
+	}
+
+	//used by template processing for the Logstash config check
+	os.Setenv("LS_INPUT_TLS_CERT", certFile)
+	os.Setenv("LS_INPUT_TLS_KEY", keyFile)
+
+	profileD := NewProfileD().
+		ExportDepPath("LS_INPUT_TLS_CERT", filepath.Join(gs.Stager.DepsIdx(), "tls", "input.crt")).
+		ExportDepPath("LS_INPUT_TLS_KEY", filepath.Join(gs.Stager.DepsIdx(), "tls", "input.key"))
+
+	return gs.WriteDependencyProfileD("input-tls", profileD)
+}
+
+// CheckInputTLSTemplates validates the TLS settings against the installed input templates: the tcp
+// input of cf-input-syslog supports neither cipher suites, protocols nor optional client
+// authentication, even combined with other input templates, and TLS on cf-input-http only works
+// with a TCP route
+func (gs *Supplier) CheckInputTLSTemplates() error {
+	inputTLS := gs.LogstashConfig.InputTLS
+	if !inputTLS.Enabled() {
+		return nil
+	}
+
+	installed := make(map[string]bool)
+	for _, t := range gs.TemplatesToInstall {
+		installed[t.Name] = true
+	}
+
+	if installed["cf-input-syslog"] {
+		if len(inputTLS.CipherSuites) > 0 || len(inputTLS.Protocols) > 0 {
+			gs.Log.Error("'input-tls.cipher-suites' and 'input-tls.protocols' are not supported by the tcp input of cf-input-syslog")
+			return errors.New("unsupported input-tls settings")
+		}
+		if strings.ToLower(strings.TrimSpace(inputTLS.ClientAuth)) == "optional" {
+			gs.Log.Error("'input-tls.client-auth: optional' is not supported by the tcp input of cf-input-syslog, use 'none' or 'required'")
+			return errors.New("unsupported input-tls client-auth")
+		}
+	}
+
+	if installed["cf-input-http"] {
+		gs.Log.Warning("TLS is enabled for cf-input-http: the http router of Cloud Foundry terminates TLS and can't forward to a TLS port, map a TCP route to the app")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package supply_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	conf "logstash/config"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// variables of the input TLS settings for the template processing
+var inputTLSVariables = []string{"INPUT_TLS", "INPUT_TLS_VERIFY_MODE", "INPUT_TLS_CLIENT_AUTH", "INPUT_TLS_MIN_VERSION",
+	"INPUT_TLS_MAX_VERSION", "INPUT_TLS_CIPHER_SUITES", "LS_INPUT_TLS_CERT", "LS_INPUT_TLS_KEY"}
+
+var _ = Describe("Input TLS", func() {
+	var (
+		buildDir string
+		depsDir  string
+		binDir   string
+		path     string
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+		binDir, err = ioutil.TempDir("", "bin")
+		Expect(err).To(BeNil())
+
+		//openssl copies the key instead of converting it to PKCS8
+		Expect(ioutil.WriteFile(filepath.Join(binDir, "openssl"), []byte(`#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -in) in="$2" ;;
+    -out) out="$2" ;;
+  esac
+  shift
+done
+cp "$in" "$out"
+`), 0755)).To(Succeed())
+		path = os.Getenv("PATH")
+		os.Setenv("PATH", binDir+string(os.PathListSeparator)+path)
+
+		certPEM, keyPEM := newClientCertificate("logstash")
+		Expect(ioutil.WriteFile(filepath.Join(buildDir, "input.crt"), []byte(certPEM), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(buildDir, "input.key"), []byte(keyPEM), 0600)).To(Succeed())
+
+		buffer = new(bytes.Buffer)
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager: libbuildpack.NewStager([]string{buildDir, "", depsDir, "0"}, logger, nil),
+			Log:    logger,
+		}
+	})
+
+	AfterEach(func() {
+		os.Setenv("PATH", path)
+		for _, name := range inputTLSVariables {
+			os.Unsetenv(name)
+		}
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+		Expect(os.RemoveAll(binDir)).To(Succeed())
+	})
+
+	Describe("InstallInputTLS", func() {
+		It("disables TLS without certificate", func() {
+			Expect(supplier.InstallInputTLS()).To(Succeed())
+			Expect(os.Getenv("INPUT_TLS")).To(Equal("false"))
+			Expect(filepath.Join(depsDir, "0", "tls")).NotTo(BeAnExistingFile())
+		})
+
+		It("installs certificate and key and maps the settings for the templates", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{
+				Certificates: []string{"clients"},
+				InputTLS: conf.InputTLS{
+					Certificate:  "input.crt",
+					Key:          "input.key",
+					ClientAuth:   "Optional",
+					Protocols:    []string{"TLSv1.2", "TLSv1.1"},
+					CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+				},
+			}
+
+			Expect(supplier.InstallInputTLS()).To(Succeed())
+			Expect(os.Getenv("INPUT_TLS")).To(Equal("true"))
+			Expect(os.Getenv("INPUT_TLS_VERIFY_MODE")).To(Equal("peer"))
+			Expect(os.Getenv("INPUT_TLS_CLIENT_AUTH")).To(Equal("optional"))
+			Expect(os.Getenv("INPUT_TLS_MIN_VERSION")).To(Equal("1.1"))
+			Expect(os.Getenv("INPUT_TLS_MAX_VERSION")).To(Equal("1.2"))
+			Expect(os.Getenv("INPUT_TLS_CIPHER_SUITES")).To(Equal(`["TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"]`))
+
+			keyFile := filepath.Join(depsDir, "0", "tls", "input.key")
+			Expect(os.Getenv("LS_INPUT_TLS_KEY")).To(Equal(keyFile))
+			info, err := os.Stat(keyFile)
+			Expect(err).To(BeNil())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			Expect(keyFile + ".orig").NotTo(BeAnExistingFile())
+
+			profileD, err := ioutil.ReadFile(filepath.Join(depsDir, "0", "profile.d", "input-tls.sh"))
+			Expect(err).To(BeNil())
+			Expect(string(profileD)).To(ContainSubstring(`export LS_INPUT_TLS_CERT="$DEPS_DIR"/'0/tls/input.crt'`))
+			Expect(string(profileD)).To(ContainSubstring(`export LS_INPUT_TLS_KEY="$DEPS_DIR"/'0/tls/input.key'`))
+		})
+
+		It("fails on an invalid client-auth", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{InputTLS: conf.InputTLS{Certificate: "input.crt", Key: "input.key", ClientAuth: "always"}}
+
+			Expect(supplier.InstallInputTLS()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Invalid value 'always' for 'input-tls.client-auth'"))
+		})
+
+		It("fails on client authentication without certificates", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{InputTLS: conf.InputTLS{Certificate: "input.crt", Key: "input.key", ClientAuth: "required"}}
+
+			Expect(supplier.InstallInputTLS()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("'input-tls.client-auth: required' requires the CA certificates of the clients"))
+		})
+
+		It("fails on invalid protocols and cipher suites", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{InputTLS: conf.InputTLS{Certificate: "input.crt", Key: "input.key", Protocols: []string{"SSLv3"}}}
+			Expect(supplier.InstallInputTLS()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Invalid protocol 'SSLv3'"))
+
+			supplier.LogstashConfig = conf.LogstashConfig{InputTLS: conf.InputTLS{Certificate: "input.crt", Key: "input.key", CipherSuites: []string{`AES"]`}}}
+			Expect(supplier.InstallInputTLS()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring(`Invalid cipher suite 'AES"]'`))
+		})
+
+		It("fails if the key doesn't belong to the certificate", func() {
+			_, keyPEM := newClientCertificate("other")
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "other.key"), []byte(keyPEM), 0600)).To(Succeed())
+			supplier.LogstashConfig = conf.LogstashConfig{InputTLS: conf.InputTLS{Certificate: "input.crt", Key: "other.key"}}
+
+			Expect(supplier.InstallInputTLS()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Invalid certificate/key pair in 'input-tls'"))
+		})
+	})
+
+	Describe("CheckInputTLSTemplates", func() {
+		templates := func(names ...string) []conf.Template {
+			templates := []conf.Template{}
+			for _, name := range names {
+				templates = append(templates, conf.Template{Name: name})
+			}
+			return templates
+		}
+
+		BeforeEach(func() {
+			supplier.LogstashConfig = conf.LogstashConfig{InputTLS: conf.InputTLS{Certificate: "input.crt", Key: "input.key"}}
+		})
+
+		It("accepts the settings supported by the installed templates", func() {
+			supplier.LogstashConfig.InputTLS.ClientAuth = "required"
+			supplier.TemplatesToInstall = templates("cf-input-syslog")
+
+			Expect(supplier.CheckInputTLSTemplates()).To(Succeed())
+		})
+
+		It("rejects cipher suites and protocols with cf-input-syslog", func() {
+			supplier.LogstashConfig.InputTLS.Protocols = []string{"TLSv1.2"}
+			supplier.TemplatesToInstall = templates("cf-input-syslog")
+
+			Expect(supplier.CheckInputTLSTemplates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("not supported by the tcp input of cf-input-syslog"))
+		})
+
+		It("rejects optional client authentication with cf-input-syslog and other inputs", func() {
+			supplier.LogstashConfig.InputTLS.ClientAuth = "optional"
+			supplier.TemplatesToInstall = templates("cf-input-http", "cf-input-syslog")
+
+			Expect(supplier.CheckInputTLSTemplates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("'input-tls.client-auth: optional' is not supported by the tcp input of cf-input-syslog"))
+		})
+
+		It("warns that cf-input-http needs a TCP route", func() {
+			supplier.LogstashConfig.InputTLS.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+			supplier.TemplatesToInstall = templates("cf-input-http")
+
+			Expect(supplier.CheckInputTLSTemplates()).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("map a TCP route to the app"))
+		})
+
+		It("ignores the templates without TLS", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{InputTLS: conf.InputTLS{ClientAuth: "optional"}}
+			supplier.TemplatesToInstall = templates("cf-input-syslog")
+
+			Expect(supplier.CheckInputTLSTemplates()).To(Succeed())
+		})
+	})
+})
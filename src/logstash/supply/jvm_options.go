@@ -0,0 +1,47 @@
+package supply
+
+import (
+	"io/ioutil"
+	"logstash/jvm"
+	"path/filepath"
+	"strings"
+)
+
+// InstallJvmOptions validates the `jvm:` section and renders the jvm.options of Logstash. The
+// options are stored in $DEPS_DIR/<idx>/jvm.options, the launcher merges them with the memory
+// settings calculated at startup.
+func (gs *Supplier) InstallJvmOptions() error {
+	config := gs.LogstashConfig.Jvm
+	options := jvm.Options{
+		GC:               config.GC,
+		GCLogging:        config.GCLogging,
+		HeapDumpOnOOM:    config.HeapDumpOnOOM,
+		HeapDumpPath:     config.HeapDumpPath,
+		SystemProperties: config.SystemProperties,
+		Options:          config.Options,
+	}
+
+	flags, err := options.Flags()
+	if err != nil {
+		gs.Log.Error("Invalid jvm settings: %s", err.Error())
+		return err
+	}
+
+	content := ""
+	for _, flag := range flags {
+		content += flag + "\n"
+	}
+	if err := ioutil.WriteFile(filepath.Join(gs.Stager.DepDir(), jvm.FileName), []byte(content), 0644); err != nil {
+		return err
+	}
+
+	//jvm.options for the plugin installation and the config check during staging
+	if err := jvm.Render(gs.Logstash.StagingLocation, append(append([]string{}, gs.MemoryFlags...), flags...)); err != nil {
+		return err
+	}
+
+	if len(flags) > 0 {
+		gs.Log.Info("JVM options: %s", strings.Join(flags, " "))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package supply
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"logstash/certificates"
+	conf "logstash/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultCertificateField = "client_cert"
+const defaultKeyField = "client_key"
+
+// names of client certificates are used for file names and environment variables
+var clientCertificateNamePattern = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+// InstallClientCertificates creates a PKCS12 keystore for every client certificate (mTLS) defined in the Logstash file
+func (gs *Supplier) InstallClientCertificates() error {
+	gs.ClientKeyStores = []KeyStore{}
+
+	if len(gs.LogstashConfig.ClientCertificates) == 0 {
+		return nil
+	}
+
+	tmpDir := filepath.Join(gs.DepTmpDir, "keystores")
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	destDir := filepath.Join(gs.Stager.DepDir(), "keystores")
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	profileD := NewProfileD()
+	envNames := make(map[string]string)
+	for _, cc := range gs.LogstashConfig.ClientCertificates {
+		name := strings.TrimSpace(cc.Name)
+		if name == "" {
+			return errors.New("client certificate without name in Logstash file")
+		}
+		if !clientCertificateNamePattern.MatchString(name) {
+			gs.Log.Error("Invalid name of client certificate '%s', only letters, digits, '-' and '_' are allowed", name)
+			return errors.New("invalid client certificate name")
+		}
+		if _, found := gs.ClientKeyStore(name); found {
+			gs.Log.Error("Client certificate '%s' is defined more than once in Logstash file", name)
+			return errors.New("client certificate defined more than once")
+		}
+		envName := KeyStoreEnvName(name)
+		if other, found := envNames[envName]; found {
+			gs.Log.Error("Client certificates '%s' and '%s' both use the environment variable %s, rename one of them", other, name, envName)
+			return errors.New("conflicting client certificate names")
+		}
+		envNames[envName] = name
+
+		certPEM, keyPEM, err := gs.readClientCertificate(cc)
+		if err != nil {
+			gs.Log.Error("Unable to read client certificate '%s': %s", name, err.Error())
+			return err
+		}
+		if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+			gs.Log.Error("Invalid certificate/key pair for client certificate '%s': %s", name, err.Error())
+			return err
+		}
+
+		certs, err := certificates.Parse(certPEM)
+		if err != nil {
+			return err
+		}
+		if err := gs.CheckCertificates(name, certs); err != nil {
+			return err
+		}
+
+		password, err := GeneratePassword()
+		if err != nil {
+			return err
+		}
+		gs.AddSecret(password)
+		keyStore := KeyStore{
+			Name:            name,
+			Type:            "PKCS12",
+			Password:        password,
+			StagingLocation: filepath.Join(destDir, name+".p12"),
+			RuntimeLocation: filepath.Join(gs.Stager.DepsIdx(), "keystores", name+".p12"),
+		}
+
+		certFile := filepath.Join(tmpDir, name+".crt")
+		keyFile := filepath.Join(tmpDir, name+".key")
+		if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return err
+		}
+
+		gs.Log.Info("----> creating KeyStore for client certificate '%s' ...", name)
+		cmd := exec.Command("openssl", "pkcs12", "-export", "-in", certFile, "-inkey", keyFile, "-name", name, "-out", keyStore.StagingLocation, "-passout", "env:LS_KEYSTORE_PASSWORD")
+		cmd.Env = append(os.Environ(), "LS_KEYSTORE_PASSWORD="+keyStore.Password)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			gs.Log.Error("%s", string(out))
+			gs.Log.Error("Error creating KeyStore for client certificate '%s': %s", name, err.Error())
+			return err
+		}
+
+		gs.ClientKeyStores = append(gs.ClientKeyStores, keyStore)
+
+		//the certificate (without key) is kept for the expiry check at startup
+		if err := ioutil.WriteFile(filepath.Join(destDir, name+".pem"), certPEM, 0644); err != nil {
+			return err
+		}
+
+		profileD.ExportDepPath(envName, keyStore.RuntimeLocation)
+		profileD.Export(envName+"_PASSWORD", keyStore.Password)
+
+		//used by template processing for the Logstash config check
+		os.Setenv(envName, keyStore.StagingLocation)
+		os.Setenv(envName+"_PASSWORD", keyStore.Password)
+	}
+
+	return gs.WriteDependencyProfileD("keystores", profileD)
+}
+
+// TemplateUsesClientCertificate reports if a template configures the keystore of its client certificate
+func (gs *Supplier) TemplateUsesClientCertificate(templateName string) (bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(gs.BPDir(), "defaults/templates/", templateName+".conf"))
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(data), ".Env.CLIENT_KEYSTORE"), nil
+}
+
+// ClientKeyStore returns the keystore created for a client certificate
+func (gs *Supplier) ClientKeyStore(name string) (KeyStore, bool) {
+	for _, ks := range gs.ClientKeyStores {
+		if ks.Name == name {
+			return ks, true
+		}
+	}
+	return KeyStore{}, false
+}
+
+func (gs *Supplier) readClientCertificate(cc conf.ClientCertificate) ([]byte, []byte, error) {
+	certificateField := cc.CertificateField
+	if certificateField == "" {
+		certificateField = defaultCertificateField
+	}
+	keyField := cc.KeyField
+	if keyField == "" {
+		keyField = defaultKeyField
+	}
+
+	return gs.readCertificateAndKey(cc.Certificate, cc.Key, cc.ServiceInstanceName, certificateField, keyField)
+}
+
+// readCertificateAndKey reads PEM certificate and key from files in the app or from the credentials of a bound service
+func (gs *Supplier) readCertificateAndKey(certificate, key, serviceInstanceName, certificateField, keyField string) ([]byte, []byte, error) {
+	serviceInstanceName = strings.TrimSpace(serviceInstanceName)
+
+	if serviceInstanceName == "" {
+		if certificate == "" || key == "" {
+			return nil, nil, errors.New("'certificate' and 'key' or 'service-instance-name' have to be defined")
+		}
+		certPEM, err := ioutil.ReadFile(filepath.Join(gs.Stager.BuildDir(), certificate))
+		if err != nil {
+			return nil, nil, err
+		}
+		keyPEM, err := ioutil.ReadFile(filepath.Join(gs.Stager.BuildDir(), key))
+		if err != nil {
+			return nil, nil, err
+		}
+		return certPEM, keyPEM, nil
+	}
+
+	service, found := gs.VcapServices.WithName(serviceInstanceName)
+	if !found {
+		return nil, nil, fmt.Errorf("service instance '%s' is not bound to the app", serviceInstanceName)
+	}
+
+	certPEM, ok := service.Credentials[certificateField].(string)
+	if !ok || certPEM == "" {
+		return nil, nil, fmt.Errorf("credentials field '%s' of service instance '%s' is missing", certificateField, serviceInstanceName)
+	}
+	keyPEM, ok := service.Credentials[keyField].(string)
+	if !ok || keyPEM == "" {
+		return nil, nil, fmt.Errorf("credentials field '%s' of service instance '%s' is missing", keyField, serviceInstanceName)
+	}
+
+	return []byte(certPEM), []byte(keyPEM), nil
+}
+
+// KeyStoreEnvName returns the name of the environment variable holding the keystore path, e.g. "LS_KEYSTORE_MY_ES"
+func KeyStoreEnvName(name string) string {
+	return "LS_KEYSTORE_" + strings.ToUpper(regexp.MustCompile("[^A-Za-z0-9]+").ReplaceAllString(name, "_"))
+}
@@ -0,0 +1,222 @@
+package supply_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	conf "logstash/config"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newClientCertificate returns a PEM certificate and its PEM key
+func newClientCertificate(commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).To(BeNil())
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+var _ = Describe("Client certificates", func() {
+	var (
+		buildDir   string
+		depsDir    string
+		tmpDir     string
+		opensslLog string
+		path       string
+		buffer     *bytes.Buffer
+		redactor   *secrets
+		supplier   *supply.Supplier
+	)
+
+	writeClientCertificate := func(name string) {
+		certPEM, keyPEM := newClientCertificate(name)
+		Expect(ioutil.WriteFile(filepath.Join(buildDir, name+".crt"), []byte(certPEM), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(buildDir, name+".key"), []byte(keyPEM), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+		tmpDir, err = ioutil.TempDir("", "tmp")
+		Expect(err).To(BeNil())
+
+		//openssl logs its arguments and the keystore password of the environment
+		binDir := filepath.Join(tmpDir, "bin")
+		opensslLog = filepath.Join(tmpDir, "openssl.log")
+		Expect(os.MkdirAll(binDir, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(binDir, "openssl"), []byte("#!/bin/sh\necho \"$@ password=$LS_KEYSTORE_PASSWORD\" >> '"+opensslLog+"'\n"), 0755)).To(Succeed())
+		path = os.Getenv("PATH")
+		os.Setenv("PATH", binDir+string(os.PathListSeparator)+path)
+
+		buffer = new(bytes.Buffer)
+		redactor = &secrets{}
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager:    libbuildpack.NewStager([]string{buildDir, "", depsDir, "0"}, logger, nil),
+			Log:       logger,
+			Redactor:  redactor,
+			DepTmpDir: tmpDir,
+		}
+	})
+
+	AfterEach(func() {
+		os.Setenv("PATH", path)
+		for _, name := range []string{"LS_KEYSTORE_ES", "LS_KEYSTORE_ES_PASSWORD", "LS_KEYSTORE_MY_ES", "LS_KEYSTORE_MY_ES_PASSWORD"} {
+			os.Unsetenv(name)
+		}
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("InstallClientCertificates", func() {
+		It("creates a keystore per client certificate and exports its path and password", func() {
+			writeClientCertificate("my-es")
+			supplier.LogstashConfig = conf.LogstashConfig{ClientCertificates: []conf.ClientCertificate{
+				{Name: "my-es", Certificate: "my-es.crt", Key: "my-es.key"},
+			}}
+
+			Expect(supplier.InstallClientCertificates()).To(Succeed())
+
+			keyStore, found := supplier.ClientKeyStore("my-es")
+			Expect(found).To(BeTrue())
+			Expect(keyStore.Type).To(Equal("PKCS12"))
+			Expect(keyStore.StagingLocation).To(Equal(filepath.Join(depsDir, "0", "keystores", "my-es.p12")))
+			Expect(*redactor).To(ConsistOf(keyStore.Password))
+
+			//the password is passed in the environment, not on the command line
+			log, err := ioutil.ReadFile(opensslLog)
+			Expect(err).To(BeNil())
+			Expect(string(log)).To(ContainSubstring("pkcs12 -export"))
+			Expect(string(log)).To(ContainSubstring("-passout env:LS_KEYSTORE_PASSWORD password=" + keyStore.Password + "\n"))
+
+			Expect(filepath.Join(depsDir, "0", "keystores", "my-es.pem")).To(BeARegularFile())
+			Expect(filepath.Join(tmpDir, "keystores")).NotTo(BeAnExistingFile())
+
+			profileD, err := ioutil.ReadFile(filepath.Join(depsDir, "0", "profile.d", "keystores.sh"))
+			Expect(err).To(BeNil())
+			Expect(string(profileD)).To(ContainSubstring(`export LS_KEYSTORE_MY_ES="$DEPS_DIR"/'0/keystores/my-es.p12'`))
+			Expect(string(profileD)).To(ContainSubstring("export LS_KEYSTORE_MY_ES_PASSWORD='" + keyStore.Password + "'"))
+			Expect(os.Getenv("LS_KEYSTORE_MY_ES_PASSWORD")).To(Equal(keyStore.Password))
+		})
+
+		It("reads certificate and key from the default fields of a service instance", func() {
+			certPEM, keyPEM := newClientCertificate("es")
+			credentials, err := json.Marshal(map[string]interface{}{"client_cert": certPEM, "client_key": keyPEM})
+			Expect(err).To(BeNil())
+			Expect(supplier.VcapServices.Parse([]byte(`{"user-provided": [{"name": "es-client", "credentials": ` + string(credentials) + `}]}`))).To(Succeed())
+			supplier.LogstashConfig = conf.LogstashConfig{ClientCertificates: []conf.ClientCertificate{
+				{Name: "es", ServiceInstanceName: "es-client"},
+			}}
+
+			Expect(supplier.InstallClientCertificates()).To(Succeed())
+			Expect(supplier.ClientKeyStores).To(HaveLen(1))
+		})
+
+		It("rejects names which aren't usable as file names", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{ClientCertificates: []conf.ClientCertificate{
+				{Name: "../es", Certificate: "es.crt", Key: "es.key"},
+			}}
+
+			Expect(supplier.InstallClientCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Invalid name of client certificate '../es'"))
+			Expect(opensslLog).NotTo(BeAnExistingFile())
+		})
+
+		It("rejects client certificates defined more than once", func() {
+			writeClientCertificate("es")
+			supplier.LogstashConfig = conf.LogstashConfig{ClientCertificates: []conf.ClientCertificate{
+				{Name: "es", Certificate: "es.crt", Key: "es.key"},
+				{Name: "es", Certificate: "es.crt", Key: "es.key"},
+			}}
+
+			Expect(supplier.InstallClientCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Client certificate 'es' is defined more than once"))
+		})
+
+		It("rejects names using the same environment variable", func() {
+			writeClientCertificate("my-es")
+			supplier.LogstashConfig = conf.LogstashConfig{ClientCertificates: []conf.ClientCertificate{
+				{Name: "my-es", Certificate: "my-es.crt", Key: "my-es.key"},
+				{Name: "my_es", Certificate: "my-es.crt", Key: "my-es.key"},
+			}}
+
+			Expect(supplier.InstallClientCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Client certificates 'my-es' and 'my_es' both use the environment variable LS_KEYSTORE_MY_ES"))
+		})
+
+		It("rejects a key which doesn't belong to the certificate", func() {
+			writeClientCertificate("es")
+			writeClientCertificate("other")
+			supplier.LogstashConfig = conf.LogstashConfig{ClientCertificates: []conf.ClientCertificate{
+				{Name: "es", Certificate: "es.crt", Key: "other.key"},
+			}}
+
+			Expect(supplier.InstallClientCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Invalid certificate/key pair for client certificate 'es'"))
+			Expect(opensslLog).NotTo(BeAnExistingFile())
+		})
+
+		It("fails if the service instance is not bound", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{ClientCertificates: []conf.ClientCertificate{
+				{Name: "es", ServiceInstanceName: "missing"},
+			}}
+
+			Expect(supplier.InstallClientCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("service instance 'missing' is not bound to the app"))
+		})
+	})
+
+	Describe("KeyStoreEnvName", func() {
+		It("upper-cases the name and replaces other characters", func() {
+			Expect(supply.KeyStoreEnvName("my-es")).To(Equal("LS_KEYSTORE_MY_ES"))
+			Expect(supply.KeyStoreEnvName("es_2")).To(Equal("LS_KEYSTORE_ES_2"))
+		})
+	})
+
+	Describe("TemplateUsesClientCertificate", func() {
+		It("finds the keystore in the template", func() {
+			supplier.BuildpackDir = filepath.Join("..", "..", "..")
+
+			uses, err := supplier.TemplateUsesClientCertificate("cf-output-elasticsearch")
+			Expect(err).To(BeNil())
+			Expect(uses).To(BeTrue())
+
+			uses, err = supplier.TemplateUsesClientCertificate("cf-input-http")
+			Expect(err).To(BeNil())
+			Expect(uses).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package supply
+
+import (
+	"logstash/logging"
+	"strings"
+)
+
+// InstallLogging validates the `logging:` section, renders log4j2.properties into the config
+// directory of Logstash and adds `log.format`, `log.level` and the slowlog thresholds to the
+// buildpack settings of logstash.yml
+func (gs *Supplier) InstallLogging() error {
+	config := gs.LogstashConfig.Logging
+	options := logging.Options{
+		Format:  strings.ToLower(config.Format),
+		Level:   config.Level,
+		Loggers: config.Loggers,
+		Slowlog: config.Slowlog,
+	}
+	if options.Format == "" && options.Level == "" && len(options.Loggers) == 0 && len(options.Slowlog) == 0 {
+		return nil
+	}
+	if options.Format == "" {
+		options.Format = logging.FormatPlain
+	}
+
+	if err := options.Validate(); err != nil {
+		gs.Log.Error("Invalid logging settings: %s", err.Error())
+		return err
+	}
+
+	for _, arg := range strings.Fields(gs.LogstashConfig.CmdArgs) {
+		if strings.HasPrefix(arg, "--log.format") || strings.HasPrefix(arg, "--log.level") {
+			gs.Log.Warning("cmd-args '%s' overrides the logging section", arg)
+		}
+	}
+
+	for key, value := range options.Settings() {
+		gs.LogstashSettings[key] = value
+	}
+	if err := options.Render(gs.Logstash.StagingLocation); err != nil {
+		return err
+	}
+
+	gs.Log.Info("Logstash logs to stdout in %s format", options.Format)
+	return nil
+}
@@ -0,0 +1,109 @@
+package supply_test
+
+import (
+	"bytes"
+
+	conf "logstash/config"
+	"logstash/settings"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logstash credentials", func() {
+	var (
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		supplier = &supply.Supplier{
+			Log:              libbuildpack.NewLogger(buffer),
+			LogstashSettings: settings.Settings{},
+		}
+	})
+
+	Describe("EvalLogstashCredentials", func() {
+		BeforeEach(func() {
+			//the credentials service is bound under a different label than the other services
+			Expect(supplier.VcapServices.Parse([]byte(`{
+				"elasticsearch": [{"name": "es", "credentials": {"username": "es-user", "password": "es-password"}}],
+				"user-provided": [
+					{"name": "other", "credentials": {"username": "other-user", "password": "other-password"}},
+					{"name": "logstash-auth", "credentials": {"user": "ls-user", "pass": "ls-password"}}
+				]
+			}`))).To(Succeed())
+		})
+
+		It("finds the service instance in any label", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{
+				ServiceInstanceName: " logstash-auth ",
+				UsernameField:       "user",
+				PasswordField:       "pass",
+			}}
+
+			Expect(supplier.EvalLogstashCredentials()).To(Succeed())
+			Expect(supplier.LogstashConfig.LogstashCredentials.ServiceInstanceName).To(Equal("logstash-auth"))
+		})
+
+		It("defaults the credentials fields", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{ServiceInstanceName: "es"}}
+
+			Expect(supplier.EvalLogstashCredentials()).To(Succeed())
+			Expect(supplier.LogstashConfig.LogstashCredentials.UsernameField).To(Equal("username"))
+			Expect(supplier.LogstashConfig.LogstashCredentials.PasswordField).To(Equal("password"))
+		})
+
+		It("fails if a credentials field is missing", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{ServiceInstanceName: "logstash-auth"}}
+
+			Expect(supplier.EvalLogstashCredentials()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Credentials field 'username' of service instance 'logstash-auth' is missing"))
+		})
+
+		It("fails if the service instance is not bound", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{ServiceInstanceName: "missing"}}
+
+			Expect(supplier.EvalLogstashCredentials()).NotTo(Succeed())
+		})
+
+		It("fails if static credentials are defined too", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{ServiceInstanceName: "es", Password: "static"}}
+
+			Expect(supplier.EvalLogstashCredentials()).NotTo(Succeed())
+		})
+	})
+
+	Describe("EscapeConfigString", func() {
+		It("escapes quotes and backslashes", func() {
+			Expect(supply.EscapeConfigString(`pa"ss\word`)).To(Equal(`pa\"ss\\word`))
+			Expect(supply.EscapeConfigString("password")).To(Equal("password"))
+		})
+	})
+
+	Describe("EvalLogstashCredentialEscapes", func() {
+		It("enables config.support_escapes for passwords with quotes", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{Username: "user", Password: `pa"ss`}}
+
+			supplier.EvalLogstashCredentialEscapes()
+			Expect(supplier.LogstashSettings).To(HaveKeyWithValue("config.support_escapes", true))
+		})
+
+		It("enables config.support_escapes for the credentials of a service instance", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{ServiceInstanceName: " logstash-auth "}}
+
+			supplier.EvalLogstashCredentialEscapes()
+			Expect(supplier.LogstashSettings).To(HaveKeyWithValue("config.support_escapes", true))
+		})
+
+		It("keeps the settings for plain passwords", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{LogstashCredentials: conf.LogstashCredentials{Username: "user", Password: "password"}}
+
+			supplier.EvalLogstashCredentialEscapes()
+			Expect(supplier.LogstashSettings).To(BeEmpty())
+		})
+	})
+})
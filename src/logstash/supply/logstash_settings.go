@@ -0,0 +1,70 @@
+package supply
+
+import (
+	"errors"
+	"fmt"
+	"logstash/settings"
+	"path/filepath"
+	"strings"
+)
+
+// InstallLogstashSettings merges the `settings:` section into the settings of the buildpack
+// (queue, dead letter queue, health check) and stores them in $DEPS_DIR/<idx>/logstash.yml,
+// the launcher merges them into the logstash.yml of Logstash at startup
+func (gs *Supplier) InstallLogstashSettings() error {
+	userSettings := settings.Flatten(gs.LogstashConfig.Settings)
+
+	problems := []string{}
+	for _, key := range userSettings.Keys() {
+		if value, ok := gs.LogstashSettings[key]; ok {
+			if fmt.Sprintf("%v", value) != fmt.Sprintf("%v", userSettings[key]) {
+				problems = append(problems, fmt.Sprintf("settings.%s conflicts with '%v' set by the buildpack, use the section of the Logstash file (queue, dead-letter-queue or health-check) instead", key, value))
+			}
+			continue
+		}
+		gs.LogstashSettings[key] = userSettings[key]
+	}
+
+	for _, key := range gs.LogstashSettings.Keys() {
+		if !settings.IsKnown(key) {
+			gs.Log.Warning("Unknown Logstash setting '%s' (Logstash %s), Logstash refuses to start if it doesn't support it", key, gs.Logstash.Version)
+			continue
+		}
+		if err := settings.Validate(key, gs.Logstash.Version); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (Logstash %s)", err.Error(), gs.Logstash.Version))
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			gs.Log.Error("Invalid Logstash settings: %s", problem)
+		}
+		return errors.New("invalid Logstash settings")
+	}
+
+	if len(gs.LogstashSettings) > 0 {
+		gs.Log.Info("Logstash settings: %s", strings.Join(gs.LogstashSettings.Keys(), ", "))
+	}
+
+	gs.CheckAppSettings()
+
+	if err := settings.KeepOriginal(gs.Logstash.StagingLocation); err != nil {
+		return err
+	}
+	return settings.WriteFile(filepath.Join(gs.Stager.DepDir(), settings.FileName), gs.LogstashSettings)
+}
+
+// CheckAppSettings warns about settings of the Logstash file which override a different value of
+// the logstash.yml of the app, the launcher merges them at startup
+func (gs *Supplier) CheckAppSettings() {
+	appSettings, err := settings.ReadFile(filepath.Join(gs.Stager.BuildDir(), settings.FileName))
+	if err != nil {
+		gs.Log.Warning("Unable to check the logstash.yml of the app against the Logstash file: %s", err.Error())
+		return
+	}
+	for _, key := range gs.LogstashSettings.Keys() {
+		if value, ok := appSettings[key]; ok && fmt.Sprintf("%v", value) != fmt.Sprintf("%v", gs.LogstashSettings[key]) {
+			gs.Log.Warning("'%s: %v' of logstash.yml is overridden by '%v' of the Logstash file", key, value, gs.LogstashSettings[key])
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package supply_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	conf "logstash/config"
+	"logstash/settings"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logstash settings", func() {
+	var (
+		buildDir string
+		depsDir  string
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(depsDir, "0"), 0755)).To(Succeed())
+
+		buffer = new(bytes.Buffer)
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager:           libbuildpack.NewStager([]string{buildDir, "", depsDir, "0"}, logger, nil),
+			Log:              logger,
+			Logstash:         supply.Dependency{Version: "6.1.3", StagingLocation: filepath.Join(depsDir, "logstash")},
+			LogstashSettings: settings.Settings{},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	Describe("InstallLogstashSettings", func() {
+		It("warns about unknown settings and keeps them", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Settings: map[string]interface{}{"pipeline.ordered": "auto", "pipeline.workers": 2}}
+
+			Expect(supplier.InstallLogstashSettings()).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Unknown Logstash setting 'pipeline.ordered' (Logstash 6.1.3)"))
+
+			written, err := settings.ReadFile(filepath.Join(depsDir, "0", settings.FileName))
+			Expect(err).To(BeNil())
+			Expect(written).To(HaveKeyWithValue("pipeline.ordered", "auto"))
+			Expect(written).To(HaveKeyWithValue("pipeline.workers", 2))
+		})
+
+		It("fails on known settings the Logstash version doesn't support", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Settings: map[string]interface{}{"pipeline.java_execution": true}}
+
+			Expect(supplier.InstallLogstashSettings()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Invalid Logstash settings: setting 'pipeline.java_execution' requires Logstash 6.3 or later (Logstash 6.1.3)"))
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package supply_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	conf "logstash/config"
+	"logstash/memory"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory", func() {
+	var (
+		buildDir string
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+
+		buffer = new(bytes.Buffer)
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager: libbuildpack.NewStager([]string{buildDir, "", "", "0"}, logger, nil),
+			Log:    logger,
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+	})
+
+	Describe("EvalLogstashFile", func() {
+		It("defaults reserved-memory to 0", func() {
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "Logstash"), []byte("heap-percentage: 80\n"), 0644)).To(Succeed())
+
+			Expect(supplier.EvalLogstashFile()).To(Succeed())
+			Expect(supplier.LogstashConfig.ReservedMemory).To(Equal(0))
+			Expect(supplier.DefaultReserved).To(BeTrue())
+		})
+
+		It("keeps the reserved-memory of the Logstash file", func() {
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "Logstash"), []byte("reserved-memory: 0\n"), 0644)).To(Succeed())
+
+			Expect(supplier.EvalLogstashFile()).To(Succeed())
+			Expect(supplier.LogstashConfig.ReservedMemory).To(Equal(0))
+			Expect(supplier.DefaultReserved).To(BeFalse())
+		})
+	})
+
+	Describe("CheckMemoryDefaults", func() {
+		var result memory.Result
+
+		BeforeEach(func() {
+			supplier.LogstashConfig = conf.LogstashConfig{HeapPercentage: 90}
+			var err error
+			result, err = memory.Calculate(memory.Settings{Limit: 2048, HeapPercentage: 90, Workers: 2})
+			Expect(err).To(BeNil())
+		})
+
+		It("warns about the heap of the former default reserved-memory", func() {
+			supplier.DefaultReserved = true
+
+			supplier.CheckMemoryDefaults(result)
+			Expect(buffer.String()).To(ContainSubstring("The heap is 1566M instead of 1530M with the former default 'reserved-memory: 300'"))
+		})
+
+		It("accepts a reserved-memory of the Logstash file", func() {
+			supplier.CheckMemoryDefaults(result)
+			Expect(buffer.String()).To(BeEmpty())
+		})
+	})
+})
@@ -1,190 +0,0 @@
-// Code generated by MockGen. DO NOT EDIT.
-// Source: supply.go
-
-// Package supply_test is a generated GoMock package.
-package supply_test
-
-import (
-	libbuildpack "github.com/cloudfoundry/libbuildpack"
-	gomock "github.com/golang/mock/gomock"
-	reflect "reflect"
-)
-
-// MockManifest is a mock of Manifest interface
-type MockManifest struct {
-	ctrl     *gomock.Controller
-	recorder *MockManifestMockRecorder
-}
-
-// MockManifestMockRecorder is the mock recorder for MockManifest
-type MockManifestMockRecorder struct {
-	mock *MockManifest
-}
-
-// NewMockManifest creates a new mock instance
-func NewMockManifest(ctrl *gomock.Controller) *MockManifest {
-	mock := &MockManifest{ctrl: ctrl}
-	mock.recorder = &MockManifestMockRecorder{mock}
-	return mock
-}
-
-// EXPECT returns an object that allows the caller to indicate expected use
-func (m *MockManifest) EXPECT() *MockManifestMockRecorder {
-	return m.recorder
-}
-
-// AllDependencyVersions mocks base method
-func (m *MockManifest) AllDependencyVersions(arg0 string) []string {
-	ret := m.ctrl.Call(m, "AllDependencyVersions", arg0)
-	ret0, _ := ret[0].([]string)
-	return ret0
-}
-
-// AllDependencyVersions indicates an expected call of AllDependencyVersions
-func (mr *MockManifestMockRecorder) AllDependencyVersions(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllDependencyVersions", reflect.TypeOf((*MockManifest)(nil).AllDependencyVersions), arg0)
-}
-
-// DefaultVersion mocks base method
-func (m *MockManifest) DefaultVersion(arg0 string) (libbuildpack.Dependency, error) {
-	ret := m.ctrl.Call(m, "DefaultVersion", arg0)
-	ret0, _ := ret[0].(libbuildpack.Dependency)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// DefaultVersion indicates an expected call of DefaultVersion
-func (mr *MockManifestMockRecorder) DefaultVersion(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DefaultVersion", reflect.TypeOf((*MockManifest)(nil).DefaultVersion), arg0)
-}
-
-// InstallDependency mocks base method
-func (m *MockManifest) InstallDependency(arg0 libbuildpack.Dependency, arg1 string) error {
-	ret := m.ctrl.Call(m, "InstallDependency", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// InstallDependency indicates an expected call of InstallDependency
-func (mr *MockManifestMockRecorder) InstallDependency(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InstallDependency", reflect.TypeOf((*MockManifest)(nil).InstallDependency), arg0, arg1)
-}
-
-// InstallOnlyVersion mocks base method
-func (m *MockManifest) InstallOnlyVersion(arg0, arg1 string) error {
-	ret := m.ctrl.Call(m, "InstallOnlyVersion", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// InstallOnlyVersion indicates an expected call of InstallOnlyVersion
-func (mr *MockManifestMockRecorder) InstallOnlyVersion(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InstallOnlyVersion", reflect.TypeOf((*MockManifest)(nil).InstallOnlyVersion), arg0, arg1)
-}
-
-// MockStager is a mock of Stager interface
-type MockStager struct {
-	ctrl     *gomock.Controller
-	recorder *MockStagerMockRecorder
-}
-
-// MockStagerMockRecorder is the mock recorder for MockStager
-type MockStagerMockRecorder struct {
-	mock *MockStager
-}
-
-// NewMockStager creates a new mock instance
-func NewMockStager(ctrl *gomock.Controller) *MockStager {
-	mock := &MockStager{ctrl: ctrl}
-	mock.recorder = &MockStagerMockRecorder{mock}
-	return mock
-}
-
-// EXPECT returns an object that allows the caller to indicate expected use
-func (m *MockStager) EXPECT() *MockStagerMockRecorder {
-	return m.recorder
-}
-
-// AddBinDependencyLink mocks base method
-func (m *MockStager) AddBinDependencyLink(arg0, arg1 string) error {
-	ret := m.ctrl.Call(m, "AddBinDependencyLink", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// AddBinDependencyLink indicates an expected call of AddBinDependencyLink
-func (mr *MockStagerMockRecorder) AddBinDependencyLink(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBinDependencyLink", reflect.TypeOf((*MockStager)(nil).AddBinDependencyLink), arg0, arg1)
-}
-
-// BuildDir mocks base method
-func (m *MockStager) BuildDir() string {
-	ret := m.ctrl.Call(m, "BuildDir")
-	ret0, _ := ret[0].(string)
-	return ret0
-}
-
-// BuildDir indicates an expected call of BuildDir
-func (mr *MockStagerMockRecorder) BuildDir() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuildDir", reflect.TypeOf((*MockStager)(nil).BuildDir))
-}
-
-// DepDir mocks base method
-func (m *MockStager) DepDir() string {
-	ret := m.ctrl.Call(m, "DepDir")
-	ret0, _ := ret[0].(string)
-	return ret0
-}
-
-// DepDir indicates an expected call of DepDir
-func (mr *MockStagerMockRecorder) DepDir() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DepDir", reflect.TypeOf((*MockStager)(nil).DepDir))
-}
-
-// DepsIdx mocks base method
-func (m *MockStager) DepsIdx() string {
-	ret := m.ctrl.Call(m, "DepsIdx")
-	ret0, _ := ret[0].(string)
-	return ret0
-}
-
-// DepsIdx indicates an expected call of DepsIdx
-func (mr *MockStagerMockRecorder) DepsIdx() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DepsIdx", reflect.TypeOf((*MockStager)(nil).DepsIdx))
-}
-
-// WriteConfigYml mocks base method
-func (m *MockStager) WriteConfigYml(arg0 interface{}) error {
-	ret := m.ctrl.Call(m, "WriteConfigYml", arg0)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// WriteConfigYml indicates an expected call of WriteConfigYml
-func (mr *MockStagerMockRecorder) WriteConfigYml(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteConfigYml", reflect.TypeOf((*MockStager)(nil).WriteConfigYml), arg0)
-}
-
-// WriteEnvFile mocks base method
-func (m *MockStager) WriteEnvFile(arg0, arg1 string) error {
-	ret := m.ctrl.Call(m, "WriteEnvFile", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// WriteEnvFile indicates an expected call of WriteEnvFile
-func (mr *MockStagerMockRecorder) WriteEnvFile(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteEnvFile", reflect.TypeOf((*MockStager)(nil).WriteEnvFile), arg0, arg1)
-}
-
-// WriteProfileD mocks base method
-func (m *MockStager) WriteProfileD(arg0, arg1 string) error {
-	ret := m.ctrl.Call(m, "WriteProfileD", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// WriteProfileD indicates an expected call of WriteProfileD
-func (mr *MockStagerMockRecorder) WriteProfileD(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteProfileD", reflect.TypeOf((*MockStager)(nil).WriteProfileD), arg0, arg1)
-}
@@ -0,0 +1,103 @@
+package supply
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ProfileD builds the content of a profile.d script. Values are shell quoted, so user
+// defined settings (spaces, quotes, $(...), backticks) are exported literally.
+type ProfileD struct {
+	lines []string
+	err   error
+}
+
+func NewProfileD() *ProfileD {
+	return &ProfileD{}
+}
+
+// Export adds `export NAME='value'`
+func (p *ProfileD) Export(name string, value string) *ProfileD {
+	return p.add(name, ShellQuote(value))
+}
+
+// ExportInt adds `export NAME=value` for a number
+func (p *ProfileD) ExportInt(name string, value int) *ProfileD {
+	return p.add(name, fmt.Sprintf("%d", value))
+}
+
+// ExportDepPath adds `export NAME="$DEPS_DIR"/'path'` for a path relative to $DEPS_DIR
+func (p *ProfileD) ExportDepPath(name string, path string) *ProfileD {
+	return p.add(name, `"$DEPS_DIR"/`+ShellQuote(path))
+}
+
+// ExportExpanded adds `export NAME="value"` where value may reference other variables.
+// The value is a buildpack defined constant, it must not contain user input.
+func (p *ProfileD) ExportExpanded(name string, value string) *ProfileD {
+	if strings.ContainsAny(value, "\"`\\") || strings.Contains(value, "$(") {
+		p.setError(fmt.Errorf("invalid value for variable '%s': %s", name, value))
+		return p
+	}
+	return p.add(name, `"`+value+`"`)
+}
+
+// AppendPath adds the directory of a variable (e.g. "$JAVA_HOME/bin") to the end of PATH
+func (p *ProfileD) AppendPath(path string) *ProfileD {
+	if !p.validPath(path) {
+		return p
+	}
+	p.lines = append(p.lines, fmt.Sprintf(`PATH="$PATH:%s"`, path))
+	return p
+}
+
+// PrependPath adds the directory of a variable (e.g. "$PYTHONHOME/bin") to the front of PATH
+func (p *ProfileD) PrependPath(path string) *ProfileD {
+	if !p.validPath(path) {
+		return p
+	}
+	p.lines = append(p.lines, fmt.Sprintf(`PATH="%s:$PATH"`, path))
+	return p
+}
+
+// Err returns the first error of an invalid variable name or value
+func (p *ProfileD) Err() error {
+	return p.err
+}
+
+func (p *ProfileD) String() string {
+	if len(p.lines) == 0 {
+		return ""
+	}
+	return strings.Join(p.lines, "\n") + "\n"
+}
+
+func (p *ProfileD) add(name string, quotedValue string) *ProfileD {
+	if !envNamePattern.MatchString(name) {
+		p.setError(fmt.Errorf("invalid environment variable name '%s'", name))
+		return p
+	}
+	p.lines = append(p.lines, fmt.Sprintf("export %s=%s", name, quotedValue))
+	return p
+}
+
+func (p *ProfileD) validPath(path string) bool {
+	if strings.ContainsAny(path, "\"`\\: ") || strings.Contains(path, "$(") {
+		p.setError(fmt.Errorf("invalid PATH entry '%s'", path))
+		return false
+	}
+	return true
+}
+
+func (p *ProfileD) setError(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// ShellQuote returns the value in single quotes, embedded single quotes are closed, escaped and reopened
+func ShellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
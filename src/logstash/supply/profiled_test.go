@@ -0,0 +1,88 @@
+package supply_test
+
+import (
+	"logstash/supply"
+	"os/exec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProfileD", func() {
+	var profileD *supply.ProfileD
+
+	BeforeEach(func() {
+		profileD = supply.NewProfileD()
+	})
+
+	evaluate := func(name string) string {
+		Expect(profileD.Err()).To(BeNil())
+		script := "DEPS_DIR='/home/vcap/deps dir'\n" + profileD.String() + `printf '%s' "$` + name + `"`
+		out, err := exec.Command("/bin/sh", "-c", script).CombinedOutput()
+		Expect(err).To(BeNil(), string(out))
+		return string(out)
+	}
+
+	Describe("Export", func() {
+		It("keeps spaces", func() {
+			profileD.Export("LS_BP_JAVA_OPTS", "-Xss1m -Dfoo=a b")
+			Expect(evaluate("LS_BP_JAVA_OPTS")).To(Equal("-Xss1m -Dfoo=a b"))
+		})
+
+		It("keeps single and double quotes", func() {
+			profileD.Export("LS_CMD_ARGS", `--name 'it''s' "quoted"`)
+			Expect(evaluate("LS_CMD_ARGS")).To(Equal(`--name 'it''s' "quoted"`))
+		})
+
+		It("does not execute shell metacharacters", func() {
+			profileD.Export("LS_CMD_ARGS", "$(echo pwned) `echo pwned`; echo pwned && $HOME \\n")
+			Expect(evaluate("LS_CMD_ARGS")).To(Equal("$(echo pwned) `echo pwned`; echo pwned && $HOME \\n"))
+		})
+
+		It("exports empty values", func() {
+			profileD.Export("LS_DO_SLEEP", "")
+			Expect(profileD.String()).To(Equal("export LS_DO_SLEEP=''\n"))
+		})
+
+		It("rejects invalid variable names", func() {
+			profileD.Export("LS_FOO=$(id)", "bar")
+			Expect(profileD.Err()).NotTo(BeNil())
+			Expect(profileD.String()).To(Equal(""))
+		})
+	})
+
+	Describe("ExportDepPath", func() {
+		It("expands $DEPS_DIR and quotes the relative path", func() {
+			profileD.ExportDepPath("LS_ROOT", "0/it's $here")
+			Expect(profileD.String()).To(Equal(`export LS_ROOT="$DEPS_DIR"/'0/it'\''s $here'` + "\n"))
+			Expect(evaluate("LS_ROOT")).To(Equal("/home/vcap/deps dir/0/it's $here"))
+		})
+	})
+
+	Describe("ExportExpanded", func() {
+		It("references other variables", func() {
+			profileD.ExportDepPath("CURATOR_HOME", "0/curator").
+				ExportExpanded("PYTHONPATH", "${CURATOR_HOME}/lib")
+			Expect(evaluate("PYTHONPATH")).To(Equal("/home/vcap/deps dir/0/curator/lib"))
+		})
+
+		It("rejects command substitutions", func() {
+			profileD.ExportExpanded("PYTHONPATH", "$(id)")
+			Expect(profileD.Err()).NotTo(BeNil())
+		})
+	})
+
+	Describe("AppendPath and PrependPath", func() {
+		It("adds directories to PATH", func() {
+			profileD.ExportDepPath("JAVA_HOME", "0/openjdk").
+				AppendPath("$JAVA_HOME/bin").
+				PrependPath("/first")
+			Expect(evaluate("PATH")).To(MatchRegexp("^/first:.*:/home/vcap/deps dir/0/openjdk/bin$"))
+		})
+
+		It("rejects entries with separators", func() {
+			profileD.AppendPath("/bin:/tmp")
+			Expect(profileD.Err()).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,143 @@
+package supply
+
+import (
+	"errors"
+	"fmt"
+	conf "logstash/config"
+	"logstash/settings"
+	"os"
+	"path/filepath"
+)
+
+// default of `queue.max_bytes` and `dead_letter_queue.max_bytes` of Logstash
+const defaultQueueMaxBytes = "1024mb"
+
+// InstallQueue validates the `queue:` section and adds it to the buildpack settings of logstash.yml
+func (gs *Supplier) InstallQueue() error {
+	queue := gs.LogstashConfig.Queue
+
+	if queue.Type == "" {
+		if queue != (conf.Queue{}) {
+			gs.Log.Error("queue.type is required with the other queue settings")
+			return errors.New("invalid queue settings")
+		}
+		return nil
+	}
+	if queue.Type != "memory" && queue.Type != "persisted" {
+		gs.Log.Error("queue.type must be 'memory' or 'persisted': '%s'", queue.Type)
+		return errors.New("invalid queue type")
+	}
+
+	for name, size := range map[string]string{"max-bytes": queue.MaxBytes, "page-capacity": queue.PageCapacity} {
+		if size == "" {
+			continue
+		}
+		if _, err := settings.ParseByteSize(size); err != nil {
+			gs.Log.Error("queue.%s: %s", name, err.Error())
+			return err
+		}
+	}
+	for name, value := range map[string]int{
+		"max-events":          queue.MaxEvents,
+		"checkpoint-writes":   queue.CheckpointWrites,
+		"checkpoint-acks":     queue.CheckpointAcks,
+		"checkpoint-interval": queue.CheckpointInterval,
+	} {
+		if value < 0 {
+			gs.Log.Error("queue.%s must not be negative", name)
+			return fmt.Errorf("invalid queue %s", name)
+		}
+	}
+
+	gs.LogstashSettings["queue.type"] = queue.Type
+	for key, value := range map[string]string{
+		"queue.max_bytes":     queue.MaxBytes,
+		"queue.page_capacity": queue.PageCapacity,
+		"path.queue":          queue.Path,
+	} {
+		if value != "" {
+			gs.LogstashSettings[key] = value
+		}
+	}
+	for key, value := range map[string]int{
+		"queue.max_events":          queue.MaxEvents,
+		"queue.checkpoint.writes":   queue.CheckpointWrites,
+		"queue.checkpoint.acks":     queue.CheckpointAcks,
+		"queue.checkpoint.interval": queue.CheckpointInterval,
+	} {
+		if value > 0 {
+			gs.LogstashSettings[key] = value
+		}
+	}
+
+	if queue.Type == "persisted" {
+		gs.Log.Warning("The persisted queue is stored on the ephemeral disk of the container. It's lost whenever the container is replaced (restage, restart, update of the platform), only events in flight during a crash of Logstash are protected")
+	}
+	return nil
+}
+
+// CheckDiskQuota verifies that the persisted queue and the dead letter queue fit into the disk
+// limit of the app together with the droplet (app and dependencies)
+func (gs *Supplier) CheckDiskQuota() error {
+	queues := [][2]string{}
+	if gs.LogstashConfig.Queue.Type == "persisted" {
+		queues = append(queues, [2]string{"queue.max-bytes", gs.LogstashConfig.Queue.MaxBytes})
+	}
+	if gs.LogstashConfig.DeadLetterQueue.Enabled {
+		queues = append(queues, [2]string{"dead-letter-queue.max-bytes", gs.LogstashConfig.DeadLetterQueue.MaxBytes})
+	}
+	if len(queues) == 0 {
+		return nil
+	}
+	if gs.VcapApp.Limits == nil || gs.VcapApp.Limits.Disk == 0 {
+		gs.Log.Warning("disk limit is unknown, the size of the queues can't be verified")
+		return nil
+	}
+
+	const mb = 1 << 20
+	queueSize := int64(0)
+	for _, queue := range queues {
+		name, maxBytes := queue[0], queue[1]
+		if maxBytes == "" {
+			maxBytes = defaultQueueMaxBytes
+		}
+		size, err := settings.ParseByteSize(maxBytes)
+		if err != nil {
+			return err
+		}
+		gs.Log.Info("Disk: %s %dM", name, size/mb)
+		queueSize += size
+	}
+
+	dropletSize := int64(0)
+	for _, dir := range []string{gs.Stager.BuildDir(), filepath.Dir(gs.Stager.DepDir())} {
+		size, err := dirSize(dir)
+		if err != nil {
+			return err
+		}
+		dropletSize += size
+	}
+
+	diskLimit := int64(gs.VcapApp.Limits.Disk) * mb
+	gs.Log.Info("Disk: queues %dM, droplet %dM, limit %dM", queueSize/mb, dropletSize/mb, diskLimit/mb)
+
+	if queueSize+dropletSize > diskLimit {
+		gs.Log.Error("The queues (%dM) and the droplet (%dM) exceed the disk limit of %dM, reduce queue.max-bytes/dead-letter-queue.max-bytes or increase the disk quota (cf push -k)", queueSize/mb, dropletSize/mb, diskLimit/mb)
+		return errors.New("queues exceed the disk limit")
+	}
+	return nil
+}
+
+func dirSize(dir string) (int64, error) {
+	size := int64(0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
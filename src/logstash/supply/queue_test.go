@@ -0,0 +1,144 @@
+package supply_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	conf "logstash/config"
+	"logstash/settings"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Queue", func() {
+	var (
+		buildDir string
+		depsDir  string
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(depsDir, "0"), 0755)).To(Succeed())
+
+		buffer = new(bytes.Buffer)
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager:           libbuildpack.NewStager([]string{buildDir, "", depsDir, "0"}, logger, nil),
+			Log:              logger,
+			LogstashSettings: settings.Settings{},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	Describe("InstallQueue", func() {
+		It("keeps the settings without queue", func() {
+			Expect(supplier.InstallQueue()).To(Succeed())
+			Expect(supplier.LogstashSettings).To(BeEmpty())
+		})
+
+		It("adds the persisted queue to the settings", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{
+				Type:             "persisted",
+				MaxBytes:         "2gb",
+				PageCapacity:     "64mb",
+				CheckpointWrites: 512,
+				Path:             "queue",
+			}}
+
+			Expect(supplier.InstallQueue()).To(Succeed())
+			Expect(supplier.LogstashSettings).To(Equal(settings.Settings{
+				"queue.type":              "persisted",
+				"queue.max_bytes":         "2gb",
+				"queue.page_capacity":     "64mb",
+				"queue.checkpoint.writes": 512,
+				"path.queue":              "queue",
+			}))
+			Expect(buffer.String()).To(ContainSubstring("The persisted queue is stored on the ephemeral disk"))
+		})
+
+		It("requires the type with other queue settings", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{MaxBytes: "2gb"}}
+
+			Expect(supplier.InstallQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("queue.type is required"))
+		})
+
+		It("rejects invalid types, sizes and negative values", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{Type: "disk"}}
+			Expect(supplier.InstallQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("queue.type must be 'memory' or 'persisted': 'disk'"))
+
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{Type: "persisted", MaxBytes: "2 gigabytes"}}
+			Expect(supplier.InstallQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("queue.max-bytes:"))
+
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{Type: "persisted", MaxEvents: -1}}
+			Expect(supplier.InstallQueue()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("queue.max-events must not be negative"))
+
+			Expect(supplier.LogstashSettings).To(BeEmpty())
+		})
+	})
+
+	Describe("CheckDiskQuota", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "app.conf"), make([]byte, 3<<20), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(depsDir, "0", "logstash.tar"), make([]byte, 5<<20), 0644)).To(Succeed())
+			supplier.VcapApp = conf.VcapApp{Limits: &conf.Limits{Disk: 1024}}
+		})
+
+		It("ignores the memory queue", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{Type: "memory", MaxBytes: "4gb"}}
+
+			Expect(supplier.CheckDiskQuota()).To(Succeed())
+			Expect(buffer.String()).To(BeEmpty())
+		})
+
+		It("accepts queues fitting into the disk limit with the droplet", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{
+				Queue:           conf.Queue{Type: "persisted", MaxBytes: "512mb"},
+				DeadLetterQueue: conf.DeadLetterQueue{Enabled: true, MaxBytes: "256mb"},
+			}
+
+			Expect(supplier.CheckDiskQuota()).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Disk: queues 768M, droplet 8M, limit 1024M"))
+		})
+
+		It("fails if the queues and the droplet exceed the disk limit", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{Type: "persisted", MaxBytes: "1020mb"}}
+
+			Expect(supplier.CheckDiskQuota()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("The queues (1020M) and the droplet (8M) exceed the disk limit of 1024M"))
+		})
+
+		It("uses the default size of Logstash", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{Type: "persisted"}}
+
+			Expect(supplier.CheckDiskQuota()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Disk: queue.max-bytes 1024M"))
+		})
+
+		It("warns if the disk limit is unknown", func() {
+			supplier.VcapApp = conf.VcapApp{}
+			supplier.LogstashConfig = conf.LogstashConfig{Queue: conf.Queue{Type: "persisted"}}
+
+			Expect(supplier.CheckDiskQuota()).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("disk limit is unknown"))
+		})
+	})
+})
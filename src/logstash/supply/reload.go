@@ -0,0 +1,23 @@
+package supply
+
+import "errors"
+
+// InstallReload validates the `reload:` section. With reload enabled the launcher starts Logstash
+// with --config.reload.automatic and re-renders the templates of conf.d on SIGHUP and every poll
+// interval.
+func (gs *Supplier) InstallReload() error {
+	reload := gs.LogstashConfig.Reload
+	if !reload.Enabled {
+		return nil
+	}
+	if reload.PollInterval < 0 {
+		gs.Log.Error("reload.poll-interval must not be negative")
+		return errors.New("invalid reload poll interval")
+	}
+
+	profileD := NewProfileD().
+		Export("LS_BP_RELOAD", "enabled").
+		ExportInt("LS_BP_RELOAD_POLL_INTERVAL", reload.PollInterval)
+
+	return gs.WriteDependencyProfileD("reload", profileD)
+}
@@ -0,0 +1,274 @@
+package supply
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/andibrunner/libbuildpack"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const sbomFileName = "sbom.cdx.json"
+
+// CycloneDX 1.4 (JSON) document written to [DEPS_DIR]/sbom.cdx.json
+type Sbom struct {
+	BomFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     SbomMetadata    `json:"metadata"`
+	Components   []SbomComponent `json:"components"`
+}
+
+type SbomMetadata struct {
+	Timestamp string         `json:"timestamp"`
+	Tools     []SbomTool     `json:"tools"`
+	Component *SbomComponent `json:"component,omitempty"`
+}
+
+type SbomTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type SbomComponent struct {
+	Type               string          `json:"type"`
+	Name               string          `json:"name"`
+	Version            string          `json:"version,omitempty"`
+	Purl               string          `json:"purl,omitempty"`
+	Hashes             []SbomHash      `json:"hashes,omitempty"`
+	Licenses           []SbomLicense   `json:"licenses,omitempty"`
+	ExternalReferences []SbomReference `json:"externalReferences,omitempty"`
+}
+
+type SbomHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// SbomLicense is either a single license or an SPDX license expression, e.g. `GPL-2.0-only WITH Classpath-exception-2.0`
+type SbomLicense struct {
+	License    *SbomLicenseChoice `json:"license,omitempty"`
+	Expression string             `json:"expression,omitempty"`
+}
+
+type SbomLicenseChoice struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type SbomReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// WriteSbom writes the SBOM, it's auxiliary: missing details (licenses, hashes, pip packages) are
+// only warned about
+func (gs *Supplier) WriteSbom() error {
+	gs.Log.BeginStep("Writing software bill of materials")
+
+	licenses, err := gs.ReadDependencyLicenses(filepath.Join(gs.BPDir(), "LICENSE-DEPENDENCIES"))
+	if err != nil {
+		gs.Log.Warning("Unable to read LICENSE-DEPENDENCIES, licenses will be missing in the SBOM: %s", err.Error())
+	}
+
+	sbom := Sbom{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: newSerialNumber(),
+		Version:      1,
+		Metadata: SbomMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []SbomTool{{Name: "logstash-buildpack", Version: gs.buildpackVersion()}},
+		},
+		Components: []SbomComponent{},
+	}
+	if gs.VcapApp.Name != "" {
+		sbom.Metadata.Component = &SbomComponent{Type: "application", Name: gs.VcapApp.Name, Version: gs.VcapApp.Version}
+	}
+
+	//manifest dependencies
+	for _, dependency := range gs.InstalledDependencies() {
+		component := SbomComponent{Type: "application", Name: dependency.Name, Version: dependency.Version}
+		if dependency.Name == "python3" || dependency.Name == "openjdk" {
+			component.Type = "framework"
+		}
+
+		entry, err := gs.Manifest.GetEntry(libbuildpack.Dependency{Name: dependency.Name, Version: dependency.Version})
+		if err != nil {
+			gs.Log.Warning("Unable to find %s %s in the manifest, hash and download URL will be missing in the SBOM: %s", dependency.Name, dependency.Version, err.Error())
+		} else {
+			if entry.SHA256 != "" {
+				component.Hashes = []SbomHash{{Alg: "SHA-256", Content: entry.SHA256}}
+			}
+			if entry.URI != "" {
+				component.ExternalReferences = []SbomReference{{Type: "distribution", URL: entry.URI}}
+			}
+		}
+		if license, ok := licenses[dependency.Name]; ok {
+			component.Licenses = []SbomLicense{NewSbomLicense(license)}
+		}
+		sbom.Components = append(sbom.Components, component)
+	}
+
+	//Logstash plugin gems
+	for _, name := range sortedKeys(gs.InstalledPlugins) {
+		version := gs.InstalledPlugins[name]
+		sbom.Components = append(sbom.Components, SbomComponent{
+			Type:    "library",
+			Name:    name,
+			Version: version,
+			Purl:    fmt.Sprintf("pkg:gem/%s@%s", name, version),
+		})
+	}
+
+	//pip packages (curator)
+	pipPackages, err := gs.ListPipPackages()
+	if err != nil {
+		gs.Log.Warning("Unable to list the installed pip packages, they will be missing in the SBOM: %s", err.Error())
+	}
+	for _, name := range sortedKeys(pipPackages) {
+		version := pipPackages[name]
+		sbom.Components = append(sbom.Components, SbomComponent{
+			Type:    "library",
+			Name:    name,
+			Version: version,
+			Purl:    fmt.Sprintf("pkg:pypi/%s@%s", strings.ToLower(name), version),
+		})
+	}
+
+	data, err := json.MarshalIndent(sbom, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	sbomFile := filepath.Join(gs.Stager.DepDir(), sbomFileName)
+	if err := ioutil.WriteFile(sbomFile, data, 0644); err != nil {
+		return err
+	}
+	gs.Log.Info("SBOM with %d components written to $DEPS_DIR/%s/%s", len(sbom.Components), gs.Stager.DepsIdx(), sbomFileName)
+
+	return nil
+}
+
+// InstalledDependencies returns the manifest dependencies installed during this staging
+func (gs *Supplier) InstalledDependencies() []Dependency {
+	installed := []Dependency{}
+	for _, dependency := range []Dependency{gs.GTE, gs.Jq, gs.Ofelia, gs.Python3, gs.Curator, gs.OpenJdk, gs.Logstash, gs.LogstashPlugins, gs.XPack} {
+		if dependency.Version != "" {
+			installed = append(installed, dependency)
+		}
+	}
+	return installed
+}
+
+// ReadDependencyLicenses returns the "License:" identifiers of all dependency sections in LICENSE-DEPENDENCIES
+func (gs *Supplier) ReadDependencyLicenses(licenseFile string) (map[string]string, error) {
+	licenses := make(map[string]string)
+
+	file, err := os.Open(licenseFile)
+	if err != nil {
+		return licenses, err
+	}
+	defer file.Close()
+
+	reSection := regexp.MustCompile("^This software depends upon, and is distributed with, `([^`]+)`:")
+	reLicense := regexp.MustCompile(`^License:\s*(.+)$`)
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := reSection.FindStringSubmatch(line); m != nil {
+			section = m[1]
+		} else if m := reLicense.FindStringSubmatch(line); m != nil && section != "" {
+			licenses[section] = strings.TrimSpace(m[1])
+		}
+	}
+
+	return licenses, scanner.Err()
+}
+
+// ListPipPackages returns the pip packages installed with curator
+func (gs *Supplier) ListPipPackages() (map[string]string, error) {
+	packages := make(map[string]string)
+
+	if gs.Python3.Version == "" {
+		return packages, nil
+	}
+
+	cmd := exec.Command(filepath.Join(gs.Python3.StagingLocation, "bin", "pip3"), "list", "--format=freeze")
+	cmd.Env = append(os.Environ(),
+		"PYTHONHOME="+gs.Python3.StagingLocation,
+		"PYTHONPATH="+filepath.Join(gs.Stager.DepDir(), "curator", "lib", PythonLibDir(gs.Python3.Version), "site-packages"))
+	out, err := cmd.Output()
+	if err != nil {
+		return packages, err
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "==", 2)
+		if len(parts) == 2 {
+			packages[parts[0]] = parts[1]
+		}
+	}
+
+	return packages, nil
+}
+
+// PythonLibDir returns the name of the lib directory of a Python version, e.g. `python3.6` for 3.6.5
+func PythonLibDir(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return "python" + version
+	}
+	return "python" + parts[0] + "." + parts[1]
+}
+
+func (gs *Supplier) buildpackVersion() string {
+	data, err := ioutil.ReadFile(filepath.Join(gs.BPDir(), "VERSION"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// licenseExpressionPattern matches SPDX expressions combining licenses or exceptions
+var licenseExpressionPattern = regexp.MustCompile(`\s(WITH|AND|OR)\s`)
+
+// NewSbomLicense returns the license of a LICENSE-DEPENDENCIES identifier: SPDX ids, expressions
+// like `GPL-2.0-only WITH Classpath-exception-2.0` or names of other licenses (`LicenseRef-<name>`)
+func NewSbomLicense(license string) SbomLicense {
+	if strings.HasPrefix(license, "LicenseRef-") {
+		return SbomLicense{License: &SbomLicenseChoice{Name: strings.TrimPrefix(license, "LicenseRef-")}}
+	}
+	if licenseExpressionPattern.MatchString(license) {
+		return SbomLicense{Expression: license}
+	}
+	return SbomLicense{License: &SbomLicenseChoice{ID: license}}
+}
+
+func newSerialNumber() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+package supply_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"logstash/supply"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sbom", func() {
+	Describe("ReadDependencyLicenses", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "sbom")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("reads the license of every dependency section", func() {
+			licenseFile := filepath.Join(dir, "LICENSE-DEPENDENCIES")
+			Expect(ioutil.WriteFile(licenseFile, []byte(`Logstash buildpack
+
+This software depends upon, and is distributed with, `+"`logstash`"+`:
+
+License: Apache-2.0
+https://www.apache.org/licenses/LICENSE-2.0
+
+
+This software depends upon, and is distributed with, `+"`x-pack`"+`:
+
+License: LicenseRef-Elastic-EULA
+
+
+This software depends upon, and is distributed with, `+"`openjdk`"+`:
+
+License: GPL-2.0-only WITH Classpath-exception-2.0
+`), 0644)).To(Succeed())
+
+			licenses, err := (&supply.Supplier{}).ReadDependencyLicenses(licenseFile)
+			Expect(err).To(BeNil())
+			Expect(licenses).To(Equal(map[string]string{
+				"logstash": "Apache-2.0",
+				"x-pack":   "LicenseRef-Elastic-EULA",
+				"openjdk":  "GPL-2.0-only WITH Classpath-exception-2.0",
+			}))
+		})
+
+		It("returns an error for a missing file", func() {
+			_, err := (&supply.Supplier{}).ReadDependencyLicenses(filepath.Join(dir, "missing"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("NewSbomLicense", func() {
+		marshal := func(license string) string {
+			data, err := json.Marshal(supply.NewSbomLicense(license))
+			Expect(err).To(BeNil())
+			return string(data)
+		}
+
+		It("uses the id of SPDX licenses", func() {
+			Expect(marshal("Apache-2.0")).To(Equal(`{"license":{"id":"Apache-2.0"}}`))
+		})
+
+		It("uses the name of other licenses", func() {
+			Expect(marshal("LicenseRef-Elastic-EULA")).To(Equal(`{"license":{"name":"Elastic-EULA"}}`))
+		})
+
+		It("uses an expression for licenses with exceptions", func() {
+			Expect(marshal("GPL-2.0-only WITH Classpath-exception-2.0")).To(Equal(`{"expression":"GPL-2.0-only WITH Classpath-exception-2.0"}`))
+		})
+	})
+
+	Describe("ParsePluginList", func() {
+		It("returns the plugins and their versions", func() {
+			plugins := supply.ParsePluginList("Using bundled JDK\nlogstash-codec-cef (5.0.2)\nlogstash-input-beats (5.0.6)\n\n")
+			Expect(plugins).To(Equal(map[string]string{
+				"logstash-codec-cef":   "5.0.2",
+				"logstash-input-beats": "5.0.6",
+			}))
+		})
+
+		It("ignores lines without a version", func() {
+			Expect(supply.ParsePluginList("logstash-codec-cef\nlogstash-codec-cef 5.0.2\n")).To(BeEmpty())
+		})
+	})
+
+	Describe("PythonLibDir", func() {
+		It("uses major and minor version", func() {
+			Expect(supply.PythonLibDir("3.6.5")).To(Equal("python3.6"))
+			Expect(supply.PythonLibDir("3.10")).To(Equal("python3.10"))
+		})
+	})
+})
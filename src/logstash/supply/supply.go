@@ -10,9 +10,14 @@ import (
 	"io/ioutil"
 	conf "logstash/config"
 
+	"crypto/x509"
 	"errors"
+	"logstash/certificates"
+	"logstash/memory"
+	"logstash/settings"
 	"logstash/util"
 	"os/exec"
+	"sort"
 )
 
 type Manifest interface {
@@ -39,11 +44,17 @@ type Stager interface {
 	WriteProfileD(string, string) error
 }
 
+type Redactor interface {
+	AddSecret(string)
+	AddCredentials(map[string]interface{})
+}
+
 type Supplier struct {
 	Version            string
 	Stager             Stager
 	Manifest           Manifest
 	Log                *libbuildpack.Logger
+	Redactor           Redactor
 	BuildpackDir       string
 	CachedDeps         map[string]string
 	DepCacheDir        string
@@ -66,6 +77,13 @@ type Supplier struct {
 	CuratorFilesExists bool
 	TemplatesToInstall []conf.Template
 	PluginsToInstall   map[string]string
+	InstalledPlugins   map[string]string
+	TrustStore         KeyStore
+	ClientKeyStores    []KeyStore
+	MemoryFlags        []string
+	MemorySummary      string
+	DefaultReserved    bool // reserved-memory is not set in the Logstash file
+	LogstashSettings   settings.Settings
 }
 
 type Dependency struct {
@@ -90,7 +108,9 @@ func Run(gs *Supplier) error {
 	gs.DepTmpDir = filepath.Join("/tmp", "dependencies", gs.Version)
 	gs.DepTmpExtractDir = filepath.Join("/tmp", "dependencies", gs.Version, "extracted")
 	gs.PluginsToInstall = make(map[string]string)
+	gs.InstalledPlugins = make(map[string]string)
 	gs.TemplatesToInstall = []conf.Template{}
+	gs.LogstashSettings = settings.Settings{}
 
 	//Eval Logstash file and prepare dir structure
 	if err := gs.EvalLogstashFile(); err != nil {
@@ -98,6 +118,16 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	gs.AddSecret(gs.LogstashConfig.LogstashCredentials.Password)
+	gs.AddSecret(EscapeConfigString(gs.LogstashConfig.LogstashCredentials.Password))
+	gs.EvalLogstashCredentialEscapes()
+
+	//Pass the secrets of the Logstash file to the launcher, they are masked at runtime too
+	if err := gs.WriteRuntimeSecrets(); err != nil {
+		gs.Log.Error("Error writing the secrets to redact: %s", err.Error())
+		return err
+	}
+
 	//Set log level
 	if strings.ToLower(gs.LogstashConfig.Buildpack.LogLevel) == "debug" {
 		os.Setenv("BP_DEBUG", "true")
@@ -132,6 +162,12 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	//Eval Logstash credentials
+	if err := gs.EvalLogstashCredentials(); err != nil {
+		gs.Log.Error("Unable to evaluate Logstash credentials: %s", err.Error())
+		return err
+	}
+
 	//Install Dependencies
 	if err := gs.InstallDependencyGTE(); err != nil {
 		gs.Log.Error("Error installing dependency GTE: %s", err.Error())
@@ -172,12 +208,54 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	//Install Client Certificates
+	if err := gs.InstallClientCertificates(); err != nil {
+		gs.Log.Error("Error installing client certificates: %s", err.Error())
+		return err
+	}
+
+	//Install TLS certificate for the input templates
+	if err := gs.InstallInputTLS(); err != nil {
+		gs.Log.Error("Error installing input TLS certificate: %s", err.Error())
+		return err
+	}
+
+	//Validate the health check
+	if err := gs.InstallHealthCheck(); err != nil {
+		gs.Log.Error("Error preparing the health check: %s", err.Error())
+		return err
+	}
+
+	//Add the dead letter queue settings
+	if err := gs.InstallDeadLetterQueue(); err != nil {
+		gs.Log.Error("Error preparing the dead letter queue: %s", err.Error())
+		return err
+	}
+
+	//Enable the automatic reload
+	if err := gs.InstallReload(); err != nil {
+		gs.Log.Error("Error preparing the reload: %s", err.Error())
+		return err
+	}
+
 	//Install templates
 	if err := gs.InstallTemplates(); err != nil {
 		gs.Log.Error("Unable to install template file: %s", err.Error())
 		return err
 	}
 
+	//Check the TLS settings against the input templates
+	if err := gs.CheckInputTLSTemplates(); err != nil {
+		gs.Log.Error("Error checking the input TLS settings: %s", err.Error())
+		return err
+	}
+
+	//Create TrustStore
+	if err := gs.CreateTrustStore(); err != nil {
+		gs.Log.Error("Error creating TrustStore: %s", err.Error())
+		return err
+	}
+
 	//Install User Certificates
 	if err := gs.InstallUserCertificates(); err != nil {
 		gs.Log.Error("Error installing user certificates: %s", err.Error())
@@ -190,12 +268,42 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	//Install Curator mode (which instances schedule Curator)
+	if err := gs.InstallCuratorMode(); err != nil {
+		gs.Log.Error("Error installing the Curator mode: %s", err.Error())
+		return err
+	}
+
 	//Install Logstash
 	if err := gs.InstallLogstash(); err != nil {
 		gs.Log.Error("Error installing Logstash: %s", err.Error())
 		return err
 	}
 
+	//Render jvm.options
+	if err := gs.InstallJvmOptions(); err != nil {
+		gs.Log.Error("Error rendering jvm.options: %s", err.Error())
+		return err
+	}
+
+	//Add the queue settings
+	if err := gs.InstallQueue(); err != nil {
+		gs.Log.Error("Error preparing the queue: %s", err.Error())
+		return err
+	}
+
+	//Render log4j2.properties
+	if err := gs.InstallLogging(); err != nil {
+		gs.Log.Error("Error configuring the logging of Logstash: %s", err.Error())
+		return err
+	}
+
+	//Store the settings for logstash.yml
+	if err := gs.InstallLogstashSettings(); err != nil {
+		gs.Log.Error("Error writing the Logstash settings: %s", err.Error())
+		return err
+	}
+
 	//Install Logstash Plugins
 	if len(gs.PluginsToInstall) > 0 { // there are plugins to install
 
@@ -233,6 +341,17 @@ func Run(gs *Supplier) error {
 		return err
 	}
 
+	//Verify the disk quota
+	if err := gs.CheckDiskQuota(); err != nil {
+		gs.Log.Error("Error checking the disk quota: %s", err.Error())
+		return err
+	}
+
+	//Write software bill of materials
+	if err := gs.WriteSbom(); err != nil {
+		gs.Log.Warning("Unable to write the software bill of materials: %s", err.Error())
+	}
+
 	//check Logstash config
 	if gs.LogstashConfig.ConfigCheck {
 		if err := gs.CheckLogstash(); err != nil {
@@ -246,11 +365,7 @@ func Run(gs *Supplier) error {
 	gs.RemoveUnusedDependencies()
 
 	//WriteConfigYml
-	config := map[string]string{
-		"LogstashVersion": gs.Logstash.Version,
-	}
-
-	if err := gs.Stager.WriteConfigYml(config); err != nil {
+	if err := gs.Stager.WriteConfigYml(gs.StagingResult()); err != nil {
 		gs.Log.Error("Error writing config.yml: %s", err.Error())
 		return err
 	}
@@ -258,6 +373,37 @@ func Run(gs *Supplier) error {
 	return nil
 }
 
+// StagingResult returns the results of the staging persisted in config.yml for finalize and the
+// startup banner of the launcher
+func (gs *Supplier) StagingResult() conf.StagingResult {
+	result := conf.StagingResult{
+		LogstashVersion:  gs.Logstash.Version,
+		OpenJdkVersion:   gs.OpenJdk.Version,
+		CuratorInstalled: gs.LogstashConfig.Curator.Install,
+		Templates:        []conf.StagedTemplate{},
+		Services:         []string{},
+		Plugins:          []string{},
+		Memory:           gs.MemorySummary,
+	}
+	for _, t := range gs.TemplatesToInstall {
+		result.Templates = append(result.Templates, conf.StagedTemplate{Name: t.Name, ServiceInstanceName: t.ServiceInstanceName})
+	}
+	for _, serviceInstances := range gs.VcapServices {
+		for _, serviceInstance := range serviceInstances {
+			result.Services = append(result.Services, serviceInstance.Name)
+		}
+	}
+	sort.Strings(result.Services)
+	for name := range gs.PluginsToInstall {
+		if version, ok := gs.InstalledPlugins[name]; ok {
+			name = fmt.Sprintf("%s (%s)", name, version)
+		}
+		result.Plugins = append(result.Plugins, name)
+	}
+	sort.Strings(result.Plugins)
+	return result
+}
+
 func (gs *Supplier) EvalTestCache() error {
 
 	if strings.ToLower(gs.LogstashConfig.Buildpack.LogLevel) == "debug" {
@@ -274,19 +420,22 @@ func (gs *Supplier) EvalTestCache() error {
 
 func (gs *Supplier) EvalLogstashFile() error {
 	const configCheck = false
-	const reservedMemory = 300
+	const reservedMemory = 0
+	const reservedMemoryUnset = -1
 	const heapPersentage = 90
 	const logLevel = "Info"
 	const noCache = false
 	const curatorInstall = false
+	const certificateExpiryWarningDays = 30
 
 	gs.LogstashConfig = conf.LogstashConfig{
-		Set:            true,
-		ConfigCheck:    configCheck,
-		ReservedMemory: reservedMemory,
-		HeapPercentage: heapPersentage,
-		Curator:        conf.Curator{Set: true, Install: curatorInstall},
-		Buildpack:      conf.Buildpack{Set: true, LogLevel: logLevel, NoCache: noCache}}
+		Set:               true,
+		ConfigCheck:       configCheck,
+		ReservedMemory:    reservedMemoryUnset,
+		HeapPercentage:    heapPersentage,
+		Curator:           conf.Curator{Set: true, Install: curatorInstall},
+		CertificateChecks: conf.CertificateChecks{ExpiryWarningDays: certificateExpiryWarningDays},
+		Buildpack:         conf.Buildpack{Set: true, LogLevel: logLevel, NoCache: noCache}}
 
 	logstashFile := filepath.Join(gs.Stager.BuildDir(), "Logstash")
 
@@ -303,6 +452,10 @@ func (gs *Supplier) EvalLogstashFile() error {
 		gs.LogstashConfig.ReservedMemory = reservedMemory
 		gs.LogstashConfig.ConfigCheck = configCheck
 	}
+	if !gs.LogstashConfig.Set || gs.LogstashConfig.ReservedMemory == reservedMemoryUnset {
+		gs.LogstashConfig.ReservedMemory = reservedMemory
+		gs.DefaultReserved = true
+	}
 	if !gs.LogstashConfig.Curator.Set {
 		gs.LogstashConfig.Curator.Install = curatorInstall //not really needed but maybe we will switch to true later
 	}
@@ -448,6 +601,11 @@ func (gs *Supplier) EvalEnvironment() error {
 	if err := gs.VcapServices.Parse([]byte(dataServices)); err != nil {
 		return err
 	}
+	for _, serviceInstances := range gs.VcapServices {
+		for _, serviceInstance := range serviceInstances {
+			gs.AddCredentials(serviceInstance.Credentials)
+		}
+	}
 
 	//check if files (also directories) exist in the application's "conf.d" directory
 	configDir := filepath.Join(gs.Stager.BuildDir(), "conf.d")
@@ -482,6 +640,75 @@ func (gs *Supplier) EvalEnvironment() error {
 	return nil
 }
 
+// EvalLogstashCredentials checks the service providing the credentials for the Logstash inputs
+func (gs *Supplier) EvalLogstashCredentials() error {
+	const usernameField = "username"
+	const passwordField = "password"
+
+	credentials := &gs.LogstashConfig.LogstashCredentials
+	credentials.ServiceInstanceName = strings.Trim(credentials.ServiceInstanceName, " ")
+	if len(credentials.ServiceInstanceName) == 0 {
+		return nil
+	}
+
+	if len(credentials.Username) > 0 || len(credentials.Password) > 0 {
+		gs.Log.Error("Either 'logstash-credentials.username/password' or 'logstash-credentials.service-instance-name' can be defined in Logstash file")
+		return errors.New("ambiguous logstash-credentials")
+	}
+	if credentials.UsernameField == "" {
+		credentials.UsernameField = usernameField
+	}
+	if credentials.PasswordField == "" {
+		credentials.PasswordField = passwordField
+	}
+
+	service, found := gs.VcapServices.WithName(credentials.ServiceInstanceName)
+	if !found {
+		gs.Log.Error("Service instance '%s' defined in 'logstash-credentials' is not bound to the app", credentials.ServiceInstanceName)
+		return errors.New("logstash-credentials service not bound")
+	}
+
+	//credentials of CredHub backed services are only interpolated at runtime
+	if _, ok := service.Credentials["credhub-ref"]; ok {
+		gs.Log.Info("----> Logstash credentials will be read from CredHub backed service instance '%s' at startup", credentials.ServiceInstanceName)
+		return nil
+	}
+
+	for _, field := range []string{credentials.UsernameField, credentials.PasswordField} {
+		if value, ok := service.Credentials[field].(string); !ok || value == "" {
+			gs.Log.Error("Credentials field '%s' of service instance '%s' is missing", field, credentials.ServiceInstanceName)
+			return errors.New("logstash-credentials field missing")
+		}
+	}
+	gs.AddSecret(service.Credentials[credentials.PasswordField].(string))
+	gs.Log.Info("----> Logstash credentials will be read from service instance '%s' at startup", credentials.ServiceInstanceName)
+
+	return nil
+}
+
+// EvalLogstashCredentialEscapes enables `config.support_escapes` if the static username or password
+// contain characters which have to be escaped in the quoted strings of the http input. The
+// credentials of a service instance are only known at runtime, the template escapes them always.
+func (gs *Supplier) EvalLogstashCredentialEscapes() {
+	credentials := gs.LogstashConfig.LogstashCredentials
+	if strings.TrimSpace(credentials.ServiceInstanceName) != "" {
+		gs.Log.Info("----> 'logstash-credentials' are read from a service instance, enabling config.support_escapes")
+		gs.LogstashSettings["config.support_escapes"] = true
+		return
+	}
+	if EscapeConfigString(credentials.Username) == credentials.Username && EscapeConfigString(credentials.Password) == credentials.Password {
+		return
+	}
+	gs.Log.Info("----> 'logstash-credentials' contain '\"' or '\\', enabling config.support_escapes")
+	gs.LogstashSettings["config.support_escapes"] = true
+}
+
+// EscapeConfigString escapes a value for a double quoted string of the Logstash configuration
+// (requires `config.support_escapes`)
+func EscapeConfigString(value string) string {
+	return strings.Replace(strings.Replace(value, `\`, `\\`, -1), `"`, `\"`, -1)
+}
+
 func (gs *Supplier) InstallDependencyGTE() error {
 	var err error
 
@@ -494,12 +721,11 @@ func (gs *Supplier) InstallDependencyGTE() error {
 		return err
 	}
 
-	content := util.TrimLines(fmt.Sprintf(`
-				export GTE_HOME=$DEPS_DIR/%s
-				PATH=$PATH:$GTE_HOME
-				`, gs.GTE.RuntimeLocation))
+	profileD := NewProfileD().
+		ExportDepPath("GTE_HOME", gs.GTE.RuntimeLocation).
+		AppendPath("$GTE_HOME")
 
-	if err := gs.WriteDependencyProfileD(gs.GTE.Name, content); err != nil {
+	if err := gs.WriteDependencyProfileD(gs.GTE.Name, profileD); err != nil {
 		return err
 	}
 
@@ -518,12 +744,11 @@ func (gs *Supplier) InstallDependencyJq() error {
 		return err
 	}
 
-	content := util.TrimLines(fmt.Sprintf(`
-				export JQ_HOME=$DEPS_DIR/%s
-				PATH=$PATH:$JQ_HOME
-				`, gs.Jq.RuntimeLocation))
+	profileD := NewProfileD().
+		ExportDepPath("JQ_HOME", gs.Jq.RuntimeLocation).
+		AppendPath("$JQ_HOME")
 
-	if err := gs.WriteDependencyProfileD(gs.Jq.Name, content); err != nil {
+	if err := gs.WriteDependencyProfileD(gs.Jq.Name, profileD); err != nil {
 		return err
 	}
 	return nil
@@ -540,12 +765,11 @@ func (gs *Supplier) InstallDependencyOfelia() error {
 		return err
 	}
 
-	content := util.TrimLines(fmt.Sprintf(`
-				export OFELIA_HOME=$DEPS_DIR/%s
-				PATH=$PATH:$OFELIA_HOME
-				`, gs.Ofelia.RuntimeLocation))
+	profileD := NewProfileD().
+		ExportDepPath("OFELIA_HOME", gs.Ofelia.RuntimeLocation).
+		AppendPath("$OFELIA_HOME")
 
-	if err := gs.WriteDependencyProfileD(gs.Ofelia.Name, content); err != nil {
+	if err := gs.WriteDependencyProfileD(gs.Ofelia.Name, profileD); err != nil {
 		return err
 	}
 	return nil
@@ -563,12 +787,11 @@ func (gs *Supplier) InstallDependencyPython3() error {
 		return err
 	}
 
-	content := util.TrimLines(fmt.Sprintf(`
-				export PYTHONHOME=$DEPS_DIR/%s
-				PATH=${PYTHONHOME}/bin:${PATH}
-				`, gs.Python3.RuntimeLocation))
+	profileD := NewProfileD().
+		ExportDepPath("PYTHONHOME", gs.Python3.RuntimeLocation).
+		PrependPath("${PYTHONHOME}/bin")
 
-	if err := gs.WriteDependencyProfileD(gs.Python3.Name, content); err != nil {
+	if err := gs.WriteDependencyProfileD(gs.Python3.Name, profileD); err != nil {
 		return err
 	}
 
@@ -587,13 +810,12 @@ func (gs *Supplier) InstallDependencyCurator() error {
 		return err
 	}
 
-	content := util.TrimLines(fmt.Sprintf(`
-				export CURATOR_HOME=$DEPS_DIR/%s/%s
-				export PYTHONPATH=${CURATOR_HOME}/lib/python3.6/site-packages
-				PATH=${CURATOR_HOME}/bin:${PATH}
-				`, gs.Stager.DepsIdx(), "curator"))
+	profileD := NewProfileD().
+		ExportDepPath("CURATOR_HOME", filepath.Join(gs.Stager.DepsIdx(), "curator")).
+		ExportExpanded("PYTHONPATH", "${CURATOR_HOME}/lib/"+PythonLibDir(gs.Python3.Version)+"/site-packages").
+		PrependPath("${CURATOR_HOME}/bin")
 
-	if err := gs.WriteDependencyProfileD(gs.Curator.Name, content); err != nil {
+	if err := gs.WriteDependencyProfileD(gs.Curator.Name, profileD); err != nil {
 		return err
 	}
 	return nil
@@ -678,12 +900,11 @@ func (gs *Supplier) InstallDependencyOpenJdk() error {
 		return err
 	}
 
-	content := util.TrimLines(fmt.Sprintf(`
-				export JAVA_HOME=$DEPS_DIR/%s
-				PATH=$PATH:$JAVA_HOME/bin
-				`, gs.OpenJdk.RuntimeLocation))
+	profileD := NewProfileD().
+		ExportDepPath("JAVA_HOME", gs.OpenJdk.RuntimeLocation).
+		AppendPath("$JAVA_HOME/bin")
 
-	if err := gs.WriteDependencyProfileD(gs.OpenJdk.Name, content); err != nil {
+	if err := gs.WriteDependencyProfileD(gs.OpenJdk.Name, profileD); err != nil {
 		return err
 	}
 	return nil
@@ -732,6 +953,11 @@ func (gs *Supplier) InstallLogstash() error {
 		return err
 	}
 
+	if gs.LogstashConfig.Shutdown.DrainTimeout < 0 {
+		gs.Log.Error("shutdown.drain-timeout must not be negative")
+		return errors.New("invalid drain timeout")
+	}
+
 	curatorEnabled := ""
 	if gs.LogstashConfig.Curator.Install {
 		curatorEnabled = "enabled"
@@ -740,44 +966,92 @@ func (gs *Supplier) InstallLogstash() error {
 	sleepCommand := ""
 	if gs.LogstashConfig.Buildpack.DoSleepCommand {
 		sleepCommand = "yes"
-	}
-
-	content := util.TrimLines(fmt.Sprintf(`
-			export LS_BP_RESERVED_MEMORY=%d
-			export LS_BP_HEAP_PERCENTAGE=%d
-			export LS_BP_JAVA_OPTS=%s
-			export LS_CMD_ARGS=%s
-			export LS_ROOT=$DEPS_DIR/%s
-			export LS_CURATOR_ENABLED=%s
-			export LS_DO_SLEEP=%s
-			export LOGSTASH_HOME=$DEPS_DIR/%s
-			PATH=$PATH:$LOGSTASH_HOME/bin
-			`,
-		gs.LogstashConfig.ReservedMemory,
-		gs.LogstashConfig.HeapPercentage,
-		gs.LogstashConfig.JavaOpts,
-		gs.LogstashConfig.CmdArgs,
-		gs.Stager.DepsIdx(),
-		curatorEnabled,
-		sleepCommand,
-		gs.Logstash.RuntimeLocation))
-
-	if err := gs.WriteDependencyProfileD(gs.Logstash.Name, content); err != nil {
+		gs.Log.Warning("buildpack.sleep-command is deprecated, it starts the maintenance mode. Use 'cf set-env <app> LS_MAINTENANCE_MODE true' and 'cf restart <app>' instead, no restage required")
+	}
+
+	profileD := NewProfileD().
+		ExportInt("LS_BP_RESERVED_MEMORY", gs.LogstashConfig.ReservedMemory).
+		ExportInt("LS_BP_HEAP_PERCENTAGE", gs.LogstashConfig.HeapPercentage).
+		ExportInt("LS_BP_WORKERS", gs.LogstashConfig.Memory.Workers).
+		ExportInt("LS_BP_THREADS", gs.LogstashConfig.Memory.Threads).
+		ExportInt("LS_BP_STACK_SIZE", gs.LogstashConfig.Memory.StackSize).
+		ExportInt("LS_BP_METASPACE", gs.LogstashConfig.Memory.Metaspace).
+		ExportInt("LS_BP_DIRECT_MEMORY", gs.LogstashConfig.Memory.DirectMemory).
+		ExportInt("LS_BP_CODE_CACHE", gs.LogstashConfig.Memory.CodeCache).
+		ExportInt("LS_BP_DRAIN_TIMEOUT", gs.LogstashConfig.Shutdown.DrainTimeout).
+		Export("LS_BP_JAVA_OPTS", gs.LogstashConfig.JavaOpts).
+		Export("LS_CMD_ARGS", gs.LogstashConfig.CmdArgs).
+		ExportDepPath("LS_ROOT", gs.Stager.DepsIdx()).
+		Export("LS_CURATOR_ENABLED", curatorEnabled).
+		Export("LS_CURATOR_MODE", gs.LogstashConfig.Curator.Mode).
+		Export("LS_DO_SLEEP", sleepCommand).
+		ExportDepPath("LOGSTASH_HOME", gs.Logstash.RuntimeLocation).
+		AppendPath("$LOGSTASH_HOME/bin")
+	gs.exportDeadLetterQueuePath(profileD)
+
+	if err := gs.WriteDependencyProfileD(gs.Logstash.Name, profileD); err != nil {
 		gs.Log.Error("Error writing profile.d script for Logstash: %s", err.Error())
 		return err
 	}
 	return nil
 }
 
-func (gs *Supplier) PrepareStagingEnvironment() error {
-	vmOptions := gs.LogstashConfig.JavaOpts
+// MemorySettings returns the input of the memory calculation, it's repeated by the launcher at startup.
+// The workers are `memory.workers`, `settings.pipeline.workers` or the CPUs of the container.
+func (gs *Supplier) MemorySettings() memory.Settings {
+	memorySettings := memory.Settings{
+		Reserved:       gs.LogstashConfig.ReservedMemory,
+		HeapPercentage: gs.LogstashConfig.HeapPercentage,
+		Workers:        gs.LogstashConfig.Memory.Workers,
+		Threads:        gs.LogstashConfig.Memory.Threads,
+		StackSize:      gs.LogstashConfig.Memory.StackSize,
+		Metaspace:      gs.LogstashConfig.Memory.Metaspace,
+		DirectMemory:   gs.LogstashConfig.Memory.DirectMemory,
+		CodeCache:      gs.LogstashConfig.Memory.CodeCache,
+	}
+	if memorySettings.Workers == 0 {
+		if workers, ok := settings.Flatten(gs.LogstashConfig.Settings).Int("pipeline.workers"); ok {
+			memorySettings.Workers = workers
+		} else {
+			memorySettings.Workers = memory.AvailableCPUs(memory.CgroupDir)
+		}
+	}
+	if gs.VcapApp.Limits != nil {
+		memorySettings.Limit = gs.VcapApp.Limits.Mem
+	}
+	return memorySettings
+}
 
-	if vmOptions != "" {
-		os.Setenv("LS_JAVA_OPTS", fmt.Sprintf("%s", vmOptions))
-	} else {
-		mem := (gs.VcapApp.Limits.Mem - gs.LogstashConfig.ReservedMemory) / 100 * gs.LogstashConfig.HeapPercentage
-		os.Setenv("LS_JAVA_OPTS", fmt.Sprintf("-Xmx%dm -Xms%dm", mem, mem))
+// reserved-memory of the Logstash file before the non heap memory was calculated separately
+const legacyReservedMemory = 300
+
+// CheckMemoryDefaults warns if the heap differs from the one of the former default reserved-memory
+// of 300M, which also covered the non heap memory
+func (gs *Supplier) CheckMemoryDefaults(result memory.Result) {
+	if !gs.DefaultReserved {
+		return
 	}
+	legacyHeap := (result.Limit - legacyReservedMemory) / 100 * gs.LogstashConfig.HeapPercentage
+	if legacyHeap == result.Heap {
+		return
+	}
+	gs.Log.Warning("The heap is %dM instead of %dM with the former default 'reserved-memory: %d': the non heap memory is calculated separately now, set 'reserved-memory' or 'memory' in the Logstash file to adjust it",
+		result.Heap, legacyHeap, legacyReservedMemory)
+}
+
+func (gs *Supplier) PrepareStagingEnvironment() error {
+	//the calculated memory settings go to jvm.options (see InstallJvmOptions), java-opts are added to them
+	result, err := memory.Calculate(gs.MemorySettings())
+	if err != nil {
+		gs.Log.Error("Invalid memory configuration: %s", err.Error())
+		return err
+	}
+	gs.Log.Info("Memory: %s", result.String())
+	gs.CheckMemoryDefaults(result)
+	gs.MemoryFlags = result.JavaOpts()
+	gs.MemorySummary = result.String()
+
+	os.Setenv("LS_JAVA_OPTS", gs.LogstashConfig.JavaOpts)
 
 	os.Setenv("JAVA_HOME", gs.OpenJdk.StagingLocation)
 	os.Setenv("PATH", os.Getenv("PATH")+":"+gs.OpenJdk.StagingLocation+"/bin")
@@ -797,31 +1071,101 @@ func (gs *Supplier) InstallUserCertificates() error {
 		return nil
 	}
 
-	localCerts, _ := gs.ReadLocalCertificates(gs.Stager.BuildDir() + "/certificates")
+	certificatesDir := filepath.Join(gs.Stager.BuildDir(), "certificates")
+	localCerts, err := gs.ReadLocalCertificates(certificatesDir)
+	if err != nil {
+		return err
+	}
 
-	for i := 0; i < len(gs.LogstashConfig.Certificates); i++ {
+	//parse all certificates first, the aliases of bundles ("<name>-1", ...) must not collide with other certificates
+	bundles := make([][]*x509.Certificate, len(gs.LogstashConfig.Certificates))
+	aliasOwners := make(map[string]string)
+	for i, certName := range gs.LogstashConfig.Certificates {
+		files := localCerts[certName]
+		if len(files) == 0 {
+			gs.Log.Error("No certificate file for '%s' found in directory '/certificates' (allowed extensions: %s)", certName, strings.Join(certificates.Extensions, ", "))
+			return errors.New("certificate file not found in directory")
+		}
+		if len(files) > 1 {
+			gs.Log.Error("Certificate '%s' is defined more than once: '%s'", certName, strings.Join(files, "', '"))
+			return errors.New("ambiguous certificate files")
+		}
 
-		localCert := localCerts[gs.LogstashConfig.Certificates[i]]
+		certs, err := certificates.ParseFile(filepath.Join(certificatesDir, files[0]))
+		if err != nil {
+			gs.Log.Error("Unable to parse certificate file '%s': %s", files[0], err.Error())
+			return err
+		}
+
+		if err := gs.CheckCertificates(certName, certs); err != nil {
+			return err
+		}
+		bundles[i] = certs
+
+		for _, alias := range certificates.Aliases(certName, len(certs)) {
+			if owner, ok := aliasOwners[alias]; ok {
+				gs.Log.Error("The TrustStore alias '%s' of certificate '%s' is already used by certificate '%s', rename one of the certificate files", alias, certName, owner)
+				return errors.New("conflicting certificate aliases")
+			}
+			aliasOwners[alias] = certName
+		}
+	}
 
-		if localCert != "" {
-			gs.Log.Info(fmt.Sprintf("----> installing user certificate '%s' to TrustStore ... ", gs.LogstashConfig.Certificates[i]))
-			certToInstall := gs.Stager.BuildDir() + "/certificates/" + localCert
-			out, err := exec.Command(fmt.Sprintf("%s/bin/keytool", gs.OpenJdk.StagingLocation), "-import", "-trustcacerts", "-keystore", fmt.Sprintf("%s/jre/lib/security/cacerts", gs.OpenJdk.StagingLocation), "-storepass", "changeit", "-noprompt", "-alias", gs.LogstashConfig.Certificates[i], "-file", certToInstall).CombinedOutput()
-			gs.Log.Info(string(out))
+	//split certificates (bundles) are kept in the droplet
+	destDir := filepath.Join(gs.Stager.DepDir(), "certificates")
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	caBundle := []byte{}
+
+	for i, certName := range gs.LogstashConfig.Certificates {
+		certs := bundles[i]
+		aliases := certificates.Aliases(certName, len(certs))
+		for c, cert := range certs {
+			certToInstall := filepath.Join(destDir, aliases[c]+".pem")
+			if err := ioutil.WriteFile(certToInstall, certificates.EncodePEM(cert), 0644); err != nil {
+				return err
+			}
+			caBundle = append(caBundle, certificates.EncodePEM(cert)...)
+
+			gs.Log.Info("----> installing user certificate '%s' (%s) to TrustStore ... ", aliases[c], cert.Subject.CommonName)
+			out, err := exec.Command(fmt.Sprintf("%s/bin/keytool", gs.OpenJdk.StagingLocation), "-import", "-trustcacerts", "-keystore", gs.TrustStore.StagingLocation, "-storetype", gs.TrustStore.Type, "-storepass", gs.TrustStore.Password, "-noprompt", "-alias", aliases[c], "-file", certToInstall).CombinedOutput()
+			gs.Log.Info("%s", string(out))
 			if err != nil {
-				gs.Log.Warning("Error installing user certificate '%s' to TrustStore: %s", gs.LogstashConfig.Certificates[i], err.Error())
+				gs.Log.Error("Error installing user certificate '%s' to TrustStore: %s", aliases[c], err.Error())
+				return err
 			}
-		} else {
-			err := errors.New("crt file for certificate not found in directory")
-			gs.Log.Error("File %s.crt not found in directory '/certificates'", gs.LogstashConfig.Certificates[i])
-			return err
 		}
 	}
 
+	//PEM bundle of all user certificates for plugins with a "cacert" setting
+	if err := ioutil.WriteFile(filepath.Join(destDir, caBundleFileName), caBundle, 0644); err != nil {
+		return err
+	}
+	os.Setenv("LS_CA_BUNDLE", filepath.Join(destDir, caBundleFileName)) // used by template processing for the Logstash config check
+
 	return nil
 
 }
 
+// checkBeatsTemplate fails if cf-input-beats is installed with another template listening on $PORT
+func (gs *Supplier) checkBeatsTemplate() error {
+	installed := map[string]bool{}
+	for _, ti := range gs.TemplatesToInstall {
+		installed[ti.Name] = true
+	}
+	if !installed["cf-input-beats"] {
+		return nil
+	}
+	for _, name := range []string{"cf-input-http", "cf-input-syslog"} {
+		if installed[name] {
+			gs.Log.Error("Template cf-input-beats can't be combined with %s in Logstash file, both listen on $PORT", name)
+			return errors.New("more than one template listening on $PORT")
+		}
+	}
+	return nil
+}
+
 func (gs *Supplier) InstallTemplates() error {
 
 	if !gs.ConfigFilesExists && len(gs.LogstashConfig.ConfigTemplates) == 0 {
@@ -894,6 +1238,24 @@ func (gs *Supplier) InstallTemplates() error {
 					} else {
 						ti.ServiceInstanceName = serviceInstanceName
 					}
+
+					clientCertificate := strings.Trim(ct.ClientCertificate, " ")
+					if len(clientCertificate) > 0 {
+						if _, found := gs.ClientKeyStore(clientCertificate); !found {
+							gs.Log.Error("Client certificate '%s' defined for template %s is not defined in 'client-certificates' of Logstash file", clientCertificate, templateName)
+							return errors.New("client certificate for template not defined")
+						}
+						usesClientCertificate, err := gs.TemplateUsesClientCertificate(templateName)
+						if err != nil {
+							gs.Log.Error("Error reading template %s: %s", templateName, err.Error())
+							return err
+						}
+						if usesClientCertificate {
+							ti.ClientCertificate = clientCertificate
+						} else {
+							gs.Log.Warning("Client certificate '%s' is defined for template %s in Logstash file but the template doesn't support client certificates, it's ignored", clientCertificate, templateName)
+						}
+					}
 					gs.TemplatesToInstall = append(gs.TemplatesToInstall, ti)
 
 					found = true
@@ -906,20 +1268,37 @@ func (gs *Supplier) InstallTemplates() error {
 		}
 	}
 
+	if err := gs.checkBeatsTemplate(); err != nil {
+		return err
+	}
+
+	if err := gs.PrepareDeadLetterQueueTemplates(); err != nil {
+		return err
+	}
+
 	//copy templates --> conf.d
 	for _, ti := range gs.TemplatesToInstall {
 
 		os.Setenv("SERVICE_INSTANCE_NAME", ti.ServiceInstanceName)
+		if len(ti.ClientCertificate) > 0 {
+			os.Setenv("CLIENT_KEYSTORE", KeyStoreEnvName(ti.ClientCertificate))
+		} else {
+			os.Setenv("CLIENT_KEYSTORE", "")
+		}
 		os.Setenv("CREDENTIALS_HOST_FIELD", gs.TemplatesConfig.Alias.CredentialsHostField)
 		os.Setenv("CREDENTIALS_USERNAME_FIELD", gs.TemplatesConfig.Alias.CredentialsUsernameField)
 		os.Setenv("CREDENTIALS_PASSWORD_FIELD", gs.TemplatesConfig.Alias.CredentialsPasswordField)
-		if len(gs.LogstashConfig.LogstashCredentials.Username) > 0 {
+		credentials := gs.LogstashConfig.LogstashCredentials
+		if len(credentials.Username) > 0 || len(credentials.ServiceInstanceName) > 0 {
 			os.Setenv("LOGSTASH_AUTH", "true")
 		} else {
 			os.Setenv("LOGSTASH_AUTH", "false")
 		}
-		os.Setenv("LOGSTASH_USERNAME", gs.LogstashConfig.LogstashCredentials.Username)
-		os.Setenv("LOGSTASH_PASSWORD", gs.LogstashConfig.LogstashCredentials.Password)
+		os.Setenv("LOGSTASH_USERNAME", EscapeConfigString(credentials.Username))
+		os.Setenv("LOGSTASH_PASSWORD", EscapeConfigString(credentials.Password))
+		os.Setenv("LOGSTASH_CREDENTIALS_SERVICE", credentials.ServiceInstanceName)
+		os.Setenv("LOGSTASH_USERNAME_FIELD", credentials.UsernameField)
+		os.Setenv("LOGSTASH_PASSWORD_FIELD", credentials.PasswordField)
 
 		templateFile := filepath.Join(gs.BPDir(), "defaults/templates/", ti.Name+".conf")
 		destFile := filepath.Join(gs.Stager.DepDir(), "conf.d", ti.Name+".conf")
@@ -967,14 +1346,32 @@ func (gs *Supplier) ListLogstashPlugins() error {
 	gs.Log.Info("----> Listing all installed Logstash plugins ...")
 
 	out, err := exec.Command(fmt.Sprintf("%s/bin/logstash-plugin", gs.Logstash.StagingLocation), "list", "--verbose").CombinedOutput()
-	gs.Log.Info(string(out))
+	gs.Log.Info("%s", string(out))
 	if err != nil {
 		gs.Log.Error("Error listing all installed Logstash plugins: %s", err.Error())
 		return err
 	}
+
+	//remember the installed plugins
+	for name, version := range ParsePluginList(string(out)) {
+		gs.InstalledPlugins[name] = version
+	}
 	return nil
 }
 
+// ParsePluginList returns the plugins and versions of `logstash-plugin list --verbose`, e.g.
+// "logstash-codec-cef (5.0.2)"
+func ParsePluginList(output string) map[string]string {
+	plugins := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 2 && strings.HasPrefix(parts[1], "(") && strings.HasSuffix(parts[1], ")") {
+			plugins[parts[0]] = strings.Trim(parts[1], "()")
+		}
+	}
+	return plugins
+}
+
 func (gs *Supplier) InstallLogstashPlugins() error {
 
 	xPackPlugins, _ := gs.ReadLocalPlugins(gs.XPack.StagingLocation)
@@ -1007,7 +1404,7 @@ func (gs *Supplier) InstallLogstashPlugins() error {
 		//Install Plugin
 		out, err := exec.Command(fmt.Sprintf("%s/bin/logstash-plugin", gs.Logstash.StagingLocation), "install", pluginToInstall).CombinedOutput()
 		if err != nil {
-			gs.Log.Error(string(out))
+			gs.Log.Error("%s", string(out))
 			gs.Log.Error("Error installing Logstash plugin %s: %s", key, err.Error())
 			return err
 		}
@@ -1042,7 +1439,7 @@ func (gs *Supplier) CheckLogstash() error {
 	found := false
 	for _, name := range list {
 		found = true
-		gs.Log.Info("      " + name)
+		gs.Log.Info("      %s", name)
 	}
 	if !found {
 		gs.Log.Warning("      " + "no files found")
@@ -1051,7 +1448,7 @@ func (gs *Supplier) CheckLogstash() error {
 	gs.Log.Info("  --> Checking Logstash config ...")
 	// check logstash config
 	out, err := exec.Command(fmt.Sprintf("%s/bin/logstash", gs.Logstash.StagingLocation), "-f", destDir, "-t").CombinedOutput()
-	gs.Log.Info(string(out))
+	gs.Log.Info("%s", string(out))
 	if err != nil {
 		gs.Log.Error("Error checking Logstash config: %s", err.Error())
 		return err
@@ -1062,10 +1459,12 @@ func (gs *Supplier) CheckLogstash() error {
 	return nil
 }
 
-func (gs *Supplier) ReadLocalCertificates(filePath string) (map[string]string, error) {
+// ReadLocalCertificates returns the certificate files of the directory by certificate name, a name
+// has more than one file if it exists with different extensions
+func (gs *Supplier) ReadLocalCertificates(filePath string) (map[string][]string, error) {
 
-	var localCerts map[string]string
-	localCerts = make(map[string]string)
+	var localCerts map[string][]string
+	localCerts = make(map[string][]string)
 
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -1075,16 +1474,12 @@ func (gs *Supplier) ReadLocalCertificates(filePath string) (map[string]string, e
 	defer file.Close()
 
 	list, _ := file.Readdirnames(0) // 0 to read all files and folders
+	sort.Strings(list)
 	for _, name := range list {
 
-		if strings.HasSuffix(name, ".crt") {
-			certParts := strings.Split(name, ".crt")
-
-			if len(certParts) == 2 {
-				certName := certParts[0]
-				localCerts[certName] = name
-			}
-
+		if certificates.HasExtension(name) {
+			certName := certificates.Name(name)
+			localCerts[certName] = append(localCerts[certName], name)
 		}
 	}
 
@@ -6,6 +6,7 @@ import (
 	"github.com/andibrunner/libbuildpack"
 	"io"
 	"io/ioutil"
+	"logstash/redact"
 	"logstash/util"
 	"os"
 	"os/exec"
@@ -17,11 +18,36 @@ func (gs *Supplier) BPDir() string {
 	return gs.BuildpackDir
 }
 
+// AddSecret registers a value which is masked in the staging output
+func (gs *Supplier) AddSecret(secret string) {
+	if gs.Redactor != nil {
+		gs.Redactor.AddSecret(secret)
+	}
+}
+
+// WriteRuntimeSecrets writes the secrets of the Logstash file which the launcher can't read from
+// the environment, they are masked in its output and diagnostics
+func (gs *Supplier) WriteRuntimeSecrets() error {
+	password := strings.TrimSpace(gs.LogstashConfig.LogstashCredentials.Password)
+	if password == "" {
+		return nil
+	}
+	//the rendered http input contains the escaped password
+	return redact.WriteSecretsFile(filepath.Join(gs.Stager.DepDir(), redact.SecretsFileName), []string{password, EscapeConfigString(password)})
+}
+
+// AddCredentials registers the secrets of service credentials which are masked in the staging output
+func (gs *Supplier) AddCredentials(credentials map[string]interface{}) {
+	if gs.Redactor != nil {
+		gs.Redactor.AddCredentials(credentials)
+	}
+}
+
 func (gs *Supplier) NewDependency(name string, versionParts int, configVersion string, doCompile bool) (Dependency, error) {
 	var dependency = Dependency{Name: name, VersionParts: versionParts, ConfigVersion: configVersion}
 
 	if parsedVersion, err := gs.SelectDependencyVersion(dependency); err != nil {
-		gs.Log.Error("Unable to determine the version of %s: %s", dependency, err.Error())
+		gs.Log.Error("Unable to determine the version of %s: %s", dependency.Name, err.Error())
 		return dependency, err
 	} else {
 		dependency.Version = parsedVersion
@@ -37,9 +63,14 @@ func (gs *Supplier) NewDependency(name string, versionParts int, configVersion s
 	return dependency, nil
 }
 
-func (gs *Supplier) WriteDependencyProfileD(dependencyName string, content string) error {
+func (gs *Supplier) WriteDependencyProfileD(dependencyName string, profileD *ProfileD) error {
+
+	if err := profileD.Err(); err != nil {
+		gs.Log.Error("Error creating profile.d script for %s: %s", dependencyName, err.Error())
+		return err
+	}
 
-	if err := gs.Stager.WriteProfileD(dependencyName+".sh", content); err != nil {
+	if err := gs.Stager.WriteProfileD(dependencyName+".sh", profileD.String()); err != nil {
 		gs.Log.Error("Error writing profile.d script for %s: %s", dependencyName, err.Error())
 		return err
 	}
@@ -69,7 +100,7 @@ func (gs *Supplier) ReadCachedDependencies() error {
 	}
 
 	for _, dirEntry := range cacheDir {
-		gs.Log.Debug(fmt.Sprintf("--> added dependency '%s' to cache list", dirEntry.Name()))
+		gs.Log.Debug("--> added dependency '%s' to cache list", dirEntry.Name())
 		gs.CachedDeps[dirEntry.Name()] = ""
 	}
 
@@ -82,7 +113,7 @@ func (gs *Supplier) InstallDependency(dependency Dependency) error {
 	//check if there are other cached versions of the same dependency
 	for cachedDep := range gs.CachedDeps {
 		if strings.HasPrefix(cachedDep, dependency.Name+"-") && cachedDep != dependency.FullName {
-			gs.Log.Debug(fmt.Sprintf("--> deleting unused dependency version '%s' from application cache", cachedDep))
+			gs.Log.Debug("--> deleting unused dependency version '%s' from application cache", cachedDep)
 			gs.CachedDeps[cachedDep] = "deleted"
 			os.RemoveAll(filepath.Join(gs.DepCacheDir, cachedDep))
 		}
@@ -176,7 +207,7 @@ func (gs *Supplier) InstallDependency(dependency Dependency) error {
 func (gs *Supplier) CopyToStage(dep Dependency) error {
 	out, err := exec.Command("cp", "-r", dep.CacheLocation+"/.", dep.StagingLocation).Output()
 	if err != nil {
-		gs.Log.Error(string(out))
+		gs.Log.Error("%s", string(out))
 		return err
 	}
 	return nil
@@ -185,10 +216,10 @@ func (gs *Supplier) CopyToStage(dep Dependency) error {
 func (gs *Supplier) LsDir(dir string) error {
 	out, err := exec.Command("ls", "-al", dir).Output()
 	if err != nil {
-		gs.Log.Error(string(out))
+		gs.Log.Error("%s", string(out))
 		return err
 	}
-	gs.Log.Info(string(out))
+	gs.Log.Info("%s", string(out))
 	return nil
 }
 
@@ -296,7 +327,7 @@ func (gs *Supplier) RemoveUnusedDependencies() error {
 
 	for cachedDep, value := range gs.CachedDeps {
 		if value == "" {
-			gs.Log.Debug(fmt.Sprintf("--> deleting unused dependency '%s' from application cache", cachedDep))
+			gs.Log.Debug("--> deleting unused dependency '%s' from application cache", cachedDep)
 			os.RemoveAll(filepath.Join(gs.DepCacheDir, cachedDep))
 		}
 	}
@@ -404,9 +435,9 @@ func (gs *Supplier) copyOutput(r io.Reader, output string) {
 
 		switch output {
 		case "stdout":
-			gs.Log.Info(scanner.Text())
+			gs.Log.Info("%s", scanner.Text())
 		case "stderr":
-			gs.Log.Error(scanner.Text())
+			gs.Log.Error("%s", scanner.Text())
 		default:
 		}
 	}
@@ -0,0 +1,103 @@
+package supply_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	conf "logstash/config"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Templates", func() {
+	var (
+		buildDir string
+		depsDir  string
+		buffer   *bytes.Buffer
+		supplier *supply.Supplier
+	)
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+
+		buffer = new(bytes.Buffer)
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager:       libbuildpack.NewStager([]string{buildDir, "", depsDir, "0"}, logger, nil),
+			Log:          logger,
+			BuildpackDir: filepath.Join("..", "..", ".."),
+		}
+		Expect(supplier.EvalTemplatesFile()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		for _, name := range inputTLSVariables {
+			os.Unsetenv(name)
+		}
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	Describe("InstallTemplates", func() {
+		It("refuses cf-input-beats with cf-input-http", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{ConfigTemplates: []conf.ConfigTemplate{{Name: "cf-input-http"}, {Name: "cf-input-beats"}}}
+
+			Expect(supplier.InstallTemplates()).To(MatchError("more than one template listening on $PORT"))
+			Expect(buffer.String()).To(ContainSubstring("Template cf-input-beats can't be combined with cf-input-http in Logstash file"))
+		})
+
+		It("refuses cf-input-beats with cf-input-syslog", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{ConfigTemplates: []conf.ConfigTemplate{{Name: "cf-input-beats"}, {Name: "cf-input-syslog"}}}
+
+			Expect(supplier.InstallTemplates()).To(MatchError("more than one template listening on $PORT"))
+			Expect(buffer.String()).To(ContainSubstring("Template cf-input-beats can't be combined with cf-input-syslog in Logstash file"))
+		})
+	})
+
+	Describe("cf-input-beats template", func() {
+		It("listens on $PORT without TLS", func() {
+			rendered := renderStagingTemplate("cf-input-beats", map[string]string{"INPUT_TLS": "false"})
+
+			Expect(rendered).To(ContainSubstring("port => {{ .Env.PORT }}"))
+			Expect(rendered).NotTo(ContainSubstring("ssl"))
+		})
+
+		It("configures TLS with client certificates", func() {
+			rendered := renderStagingTemplate("cf-input-beats", map[string]string{
+				"INPUT_TLS":               "true",
+				"INPUT_TLS_VERIFY_MODE":   "force_peer",
+				"INPUT_TLS_CIPHER_SUITES": `["TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"]`,
+				"INPUT_TLS_MIN_VERSION":   "1.2",
+				"INPUT_TLS_MAX_VERSION":   "1.2",
+			})
+
+			Expect(rendered).To(ContainSubstring(`ssl_certificate => "{{ .Env.LS_INPUT_TLS_CERT }}"`))
+			Expect(rendered).To(ContainSubstring(`ssl_key => "{{ .Env.LS_INPUT_TLS_KEY }}"`))
+			Expect(rendered).To(ContainSubstring(`ssl_verify_mode => "force_peer"`))
+			Expect(rendered).To(ContainSubstring(`ssl_certificate_authorities => ["{{ .Env.LS_CA_BUNDLE }}"]`))
+			Expect(rendered).To(ContainSubstring(`cipher_suites => ["TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"]`))
+			Expect(rendered).To(ContainSubstring("tls_min_version => 1.2"))
+			Expect(rendered).To(ContainSubstring("tls_max_version => 1.2"))
+		})
+
+		It("doesn't verify clients with verify mode none", func() {
+			rendered := renderStagingTemplate("cf-input-beats", map[string]string{
+				"INPUT_TLS":             "true",
+				"INPUT_TLS_VERIFY_MODE": "none",
+			})
+
+			Expect(rendered).To(ContainSubstring(`ssl_verify_mode => "none"`))
+			Expect(rendered).NotTo(ContainSubstring("ssl_certificate_authorities"))
+			Expect(rendered).NotTo(ContainSubstring("cipher_suites"))
+		})
+	})
+})
@@ -0,0 +1,117 @@
+package supply
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"logstash/certificates"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const trustStoreFileName = "truststore.jks"
+const caBundleFileName = "ca-bundle.pem"
+
+// key- or truststore created during staging
+type KeyStore struct {
+	Name            string
+	Type            string
+	Password        string
+	StagingLocation string
+	RuntimeLocation string
+}
+
+// CreateTrustStore copies the default CAs of the JDK into a truststore with a generated password
+func (gs *Supplier) CreateTrustStore() error {
+	password, err := GeneratePassword()
+	if err != nil {
+		return err
+	}
+
+	gs.AddSecret(password)
+
+	gs.TrustStore = KeyStore{
+		Name:            "truststore",
+		Type:            "JKS",
+		Password:        password,
+		StagingLocation: filepath.Join(gs.Stager.DepDir(), "truststore", trustStoreFileName),
+		RuntimeLocation: filepath.Join(gs.Stager.DepsIdx(), "truststore", trustStoreFileName),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(gs.TrustStore.StagingLocation), 0755); err != nil {
+		return err
+	}
+	os.Remove(gs.TrustStore.StagingLocation)
+
+	gs.Log.Info("----> creating TrustStore from the JDK default CAs ...")
+	out, err := exec.Command(fmt.Sprintf("%s/bin/keytool", gs.OpenJdk.StagingLocation), "-importkeystore", "-noprompt",
+		"-srckeystore", fmt.Sprintf("%s/jre/lib/security/cacerts", gs.OpenJdk.StagingLocation), "-srcstorepass", "changeit",
+		"-destkeystore", gs.TrustStore.StagingLocation, "-deststoretype", gs.TrustStore.Type, "-deststorepass", gs.TrustStore.Password).CombinedOutput()
+	if err != nil {
+		gs.Log.Error("%s", string(out))
+		return err
+	}
+
+	//truststore for template processing and the Logstash config check during staging
+	os.Setenv("LS_TRUSTSTORE", gs.TrustStore.StagingLocation)
+	os.Setenv("LS_TRUSTSTORE_TYPE", gs.TrustStore.Type)
+	os.Setenv("LS_TRUSTSTORE_PASSWORD", gs.TrustStore.Password)
+	os.Setenv("LS_JAVA_OPTS", fmt.Sprintf("%s -Djavax.net.ssl.trustStore=%s -Djavax.net.ssl.trustStoreType=%s -Djavax.net.ssl.trustStorePassword=%s",
+		os.Getenv("LS_JAVA_OPTS"), gs.TrustStore.StagingLocation, gs.TrustStore.Type, gs.TrustStore.Password))
+
+	profileD := NewProfileD().
+		ExportDepPath("LS_TRUSTSTORE", gs.TrustStore.RuntimeLocation).
+		Export("LS_TRUSTSTORE_TYPE", gs.TrustStore.Type).
+		Export("LS_TRUSTSTORE_PASSWORD", gs.TrustStore.Password).
+		ExportExpanded("LS_TRUSTSTORE_OPTS", "-Djavax.net.ssl.trustStore=$LS_TRUSTSTORE -Djavax.net.ssl.trustStoreType=$LS_TRUSTSTORE_TYPE -Djavax.net.ssl.trustStorePassword=$LS_TRUSTSTORE_PASSWORD").
+		ExportInt("LS_CERT_EXPIRY_WARNING_DAYS", gs.LogstashConfig.CertificateChecks.ExpiryWarningDays)
+
+	//PEM bundle of the user certificates (see InstallUserCertificates)
+	if len(gs.LogstashConfig.Certificates) > 0 {
+		profileD.ExportDepPath("LS_CA_BUNDLE", filepath.Join(gs.Stager.DepsIdx(), "certificates", caBundleFileName))
+	}
+
+	return gs.WriteDependencyProfileD("truststore", profileD)
+}
+
+// GeneratePassword returns a random password for key- and truststores
+func GeneratePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CheckCertificates prints subject, issuer and expiry of the certificates and checks expiry and chain
+func (gs *Supplier) CheckCertificates(name string, certs []*x509.Certificate) error {
+	now := time.Now()
+	warningDays := gs.LogstashConfig.CertificateChecks.ExpiryWarningDays
+	expired := false
+
+	for _, cert := range certs {
+		gs.Log.Info("----> certificate '%s': %s", name, certificates.Describe(cert))
+
+		if certificates.Expired(cert, now) {
+			expired = true
+			gs.Log.Warning("Certificate '%s' (%s) expired on %s", name, cert.Subject.CommonName, cert.NotAfter.UTC().Format("2006-01-02"))
+		} else if certificates.ExpiresWithin(cert, now, warningDays) {
+			gs.Log.Warning("Certificate '%s' (%s) expires in %d days", name, cert.Subject.CommonName, certificates.DaysLeft(cert, now))
+		}
+	}
+
+	if err := certificates.CheckChain(certs); err != nil {
+		gs.Log.Warning("Certificate '%s': %s", name, err.Error())
+	}
+
+	if expired && gs.LogstashConfig.CertificateChecks.FailOnExpired {
+		gs.Log.Error("Certificate '%s' is expired and 'certificate-checks.fail-on-expired' is set", name)
+		return errors.New("expired certificate")
+	}
+
+	return nil
+}
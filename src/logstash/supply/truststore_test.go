@@ -0,0 +1,159 @@
+package supply_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"logstash/certificates"
+	conf "logstash/config"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type secrets []string
+
+func (s *secrets) AddSecret(secret string)                           { *s = append(*s, secret) }
+func (s *secrets) AddCredentials(credentials map[string]interface{}) {}
+
+// variables of the truststore set for the template processing during staging
+var stagingVariables = []string{"LS_TRUSTSTORE", "LS_TRUSTSTORE_TYPE", "LS_TRUSTSTORE_PASSWORD", "LS_JAVA_OPTS", "LS_CA_BUNDLE"}
+
+var _ = Describe("TrustStore", func() {
+	var (
+		buildDir   string
+		depsDir    string
+		jdkDir     string
+		keytoolLog string
+		buffer     *bytes.Buffer
+		redactor   *secrets
+		supplier   *supply.Supplier
+		environ    map[string]string
+	)
+
+	BeforeEach(func() {
+		environ = map[string]string{}
+		for _, name := range stagingVariables {
+			if value, ok := os.LookupEnv(name); ok {
+				environ[name] = value
+			}
+		}
+
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+
+		jdkDir = filepath.Join(depsDir, "openjdk")
+		keytoolLog = writeKeytool(jdkDir)
+
+		buffer = new(bytes.Buffer)
+		redactor = &secrets{}
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager:   libbuildpack.NewStager([]string{buildDir, "", depsDir, "0"}, logger, nil),
+			Log:      logger,
+			Redactor: redactor,
+			OpenJdk:  supply.Dependency{StagingLocation: jdkDir},
+		}
+	})
+
+	AfterEach(func() {
+		for _, name := range stagingVariables {
+			if value, ok := environ[name]; ok {
+				os.Setenv(name, value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	Describe("CreateTrustStore", func() {
+		It("copies the JDK default CAs into a truststore with a generated password", func() {
+			Expect(supplier.CreateTrustStore()).To(Succeed())
+
+			password := supplier.TrustStore.Password
+			Expect(password).To(HaveLen(48))
+			Expect(*redactor).To(ConsistOf(password))
+			Expect(supplier.TrustStore.StagingLocation).To(Equal(filepath.Join(depsDir, "0", "truststore", "truststore.jks")))
+			Expect(supplier.TrustStore.RuntimeLocation).To(Equal(filepath.Join("0", "truststore", "truststore.jks")))
+
+			log, err := ioutil.ReadFile(keytoolLog)
+			Expect(err).To(BeNil())
+			Expect(string(log)).To(ContainSubstring("-importkeystore -noprompt -srckeystore " + filepath.Join(jdkDir, "jre", "lib", "security", "cacerts")))
+			Expect(string(log)).To(ContainSubstring("-destkeystore " + supplier.TrustStore.StagingLocation + " -deststoretype JKS -deststorepass " + password))
+
+			Expect(os.Getenv("LS_TRUSTSTORE")).To(Equal(supplier.TrustStore.StagingLocation))
+			Expect(os.Getenv("LS_JAVA_OPTS")).To(ContainSubstring("-Djavax.net.ssl.trustStorePassword=" + password))
+
+			profileD, err := ioutil.ReadFile(filepath.Join(depsDir, "0", "profile.d", "truststore.sh"))
+			Expect(err).To(BeNil())
+			Expect(string(profileD)).To(ContainSubstring(`export LS_TRUSTSTORE="$DEPS_DIR"/'0/truststore/truststore.jks'`))
+			Expect(string(profileD)).NotTo(ContainSubstring("LS_CA_BUNDLE"))
+		})
+
+		It("exports the CA bundle of the user certificates", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Certificates: []string{"ca"}}
+
+			Expect(supplier.CreateTrustStore()).To(Succeed())
+
+			profileD, err := ioutil.ReadFile(filepath.Join(depsDir, "0", "profile.d", "truststore.sh"))
+			Expect(err).To(BeNil())
+			Expect(string(profileD)).To(ContainSubstring(`export LS_CA_BUNDLE="$DEPS_DIR"/'0/certificates/ca-bundle.pem'`))
+		})
+
+		It("imports the user certificates into the created truststore", func() {
+			Expect(os.MkdirAll(filepath.Join(buildDir, "certificates"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(buildDir, "certificates", "ca.pem"), certificates.EncodePEM(newCertificate("root")), 0644)).To(Succeed())
+			supplier.LogstashConfig = conf.LogstashConfig{Certificates: []string{"ca"}}
+
+			Expect(supplier.CreateTrustStore()).To(Succeed())
+			Expect(supplier.InstallUserCertificates()).To(Succeed())
+
+			log, err := ioutil.ReadFile(keytoolLog)
+			Expect(err).To(BeNil())
+			Expect(string(log)).To(ContainSubstring("-import -trustcacerts -keystore " + supplier.TrustStore.StagingLocation + " -storetype JKS -storepass " + supplier.TrustStore.Password))
+			Expect(os.Getenv("LS_CA_BUNDLE")).To(Equal(filepath.Join(depsDir, "0", "certificates", "ca-bundle.pem")))
+		})
+
+		It("fails if keytool fails", func() {
+			Expect(ioutil.WriteFile(filepath.Join(jdkDir, "bin", "keytool"), []byte("#!/bin/sh\necho 'keytool error'\nexit 1\n"), 0755)).To(Succeed())
+
+			Expect(supplier.CreateTrustStore()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("keytool error"))
+		})
+	})
+
+	Describe("CheckCertificates", func() {
+		It("warns about expired certificates", func() {
+			cert := newCertificateValidUntil("root", time.Now().Add(-time.Minute))
+
+			Expect(supplier.CheckCertificates("ca", []*x509.Certificate{cert})).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Certificate 'ca' (root) expired on"))
+		})
+
+		It("fails on expired certificates with fail-on-expired", func() {
+			cert := newCertificateValidUntil("root", time.Now().Add(-time.Minute))
+			supplier.LogstashConfig.CertificateChecks.FailOnExpired = true
+
+			Expect(supplier.CheckCertificates("ca", []*x509.Certificate{cert})).NotTo(Succeed())
+		})
+
+		It("warns about certificates expiring soon", func() {
+			cert := newCertificateValidUntil("root", time.Now().Add(24*time.Hour))
+			supplier.LogstashConfig.CertificateChecks.ExpiryWarningDays = 30
+
+			Expect(supplier.CheckCertificates("ca", []*x509.Certificate{cert})).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Certificate 'ca' (root) expires in"))
+		})
+	})
+})
@@ -0,0 +1,157 @@
+package supply_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"logstash/certificates"
+	conf "logstash/config"
+	"logstash/supply"
+
+	"github.com/andibrunner/libbuildpack"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func newCertificate(commonName string) *x509.Certificate {
+	return newCertificateValidUntil(commonName, time.Now().Add(time.Hour))
+}
+
+func newCertificateValidUntil(commonName string, notAfter time.Time) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).To(BeNil())
+	return cert
+}
+
+// writeKeytool installs a keytool into jdkDir which logs its arguments to the returned file
+func writeKeytool(jdkDir string) string {
+	log := filepath.Join(jdkDir, "keytool.log")
+	Expect(os.MkdirAll(filepath.Join(jdkDir, "bin"), 0755)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(jdkDir, "bin", "keytool"), []byte("#!/bin/sh\necho \"$@\" >> '"+log+"'\n"), 0755)).To(Succeed())
+	return log
+}
+
+var _ = Describe("User certificates", func() {
+	var (
+		buildDir   string
+		depsDir    string
+		keytoolLog string
+		buffer     *bytes.Buffer
+		supplier   *supply.Supplier
+	)
+
+	writeCertificates := func(name string, certs ...*x509.Certificate) {
+		data := []byte{}
+		for _, cert := range certs {
+			data = append(data, certificates.EncodePEM(cert)...)
+		}
+		Expect(ioutil.WriteFile(filepath.Join(buildDir, "certificates", name), data, 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "build")
+		Expect(err).To(BeNil())
+		depsDir, err = ioutil.TempDir("", "deps")
+		Expect(err).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(buildDir, "certificates"), 0755)).To(Succeed())
+
+		jdkDir := filepath.Join(depsDir, "openjdk")
+		keytoolLog = writeKeytool(jdkDir)
+
+		buffer = new(bytes.Buffer)
+		logger := libbuildpack.NewLogger(buffer)
+		supplier = &supply.Supplier{
+			Stager:     libbuildpack.NewStager([]string{buildDir, "", depsDir, "0"}, logger, nil),
+			Log:        logger,
+			OpenJdk:    supply.Dependency{StagingLocation: jdkDir},
+			TrustStore: supply.KeyStore{Type: "JKS", Password: "secret", StagingLocation: filepath.Join(depsDir, "truststore.jks")},
+		}
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("LS_CA_BUNDLE")
+		Expect(os.RemoveAll(buildDir)).To(Succeed())
+		Expect(os.RemoveAll(depsDir)).To(Succeed())
+	})
+
+	Describe("InstallUserCertificates", func() {
+		It("imports every certificate of a bundle and writes the CA bundle", func() {
+			writeCertificates("ca.pem", newCertificate("intermediate"), newCertificate("root"))
+			writeCertificates("es.crt", newCertificate("es"))
+			supplier.LogstashConfig = conf.LogstashConfig{Certificates: []string{"ca", "es"}}
+
+			Expect(supplier.InstallUserCertificates()).To(Succeed())
+
+			log, err := ioutil.ReadFile(keytoolLog)
+			Expect(err).To(BeNil())
+			Expect(string(log)).To(ContainSubstring("-alias ca "))
+			Expect(string(log)).To(ContainSubstring("-alias ca-1 "))
+			Expect(string(log)).To(ContainSubstring("-alias es "))
+
+			bundle, err := certificates.ParseFile(filepath.Join(depsDir, "0", "certificates", "ca-bundle.pem"))
+			Expect(err).To(BeNil())
+			Expect(bundle).To(HaveLen(3))
+		})
+
+		It("fails if a listed certificate exists with different extensions", func() {
+			writeCertificates("ca.pem", newCertificate("root"))
+			writeCertificates("ca.crt", newCertificate("root"))
+			supplier.LogstashConfig = conf.LogstashConfig{Certificates: []string{"ca"}}
+
+			Expect(supplier.InstallUserCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("Certificate 'ca' is defined more than once: 'ca.crt', 'ca.pem'"))
+			Expect(keytoolLog).NotTo(BeAnExistingFile())
+		})
+
+		It("ignores duplicate files of certificates which are not listed", func() {
+			writeCertificates("ca.pem", newCertificate("root"))
+			writeCertificates("other.pem", newCertificate("other"))
+			writeCertificates("other.crt", newCertificate("other"))
+			supplier.LogstashConfig = conf.LogstashConfig{Certificates: []string{"ca"}}
+
+			Expect(supplier.InstallUserCertificates()).To(Succeed())
+		})
+
+		It("fails if the alias of a bundle collides with another certificate", func() {
+			writeCertificates("ca.pem", newCertificate("intermediate"), newCertificate("root"))
+			writeCertificates("ca-1.pem", newCertificate("other"))
+			supplier.LogstashConfig = conf.LogstashConfig{Certificates: []string{"ca", "ca-1"}}
+
+			Expect(supplier.InstallUserCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("The TrustStore alias 'ca-1' of certificate 'ca-1' is already used by certificate 'ca'"))
+			Expect(keytoolLog).NotTo(BeAnExistingFile())
+		})
+
+		It("fails if a listed certificate is missing", func() {
+			supplier.LogstashConfig = conf.LogstashConfig{Certificates: []string{"ca"}}
+
+			Expect(supplier.InstallUserCertificates()).NotTo(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("No certificate file for 'ca' found"))
+		})
+	})
+})